@@ -1,30 +1,42 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	custMiddleware "github.com/forums/app/middleware"
 
 	"github.com/forums/app/config"
+	filterModels "github.com/forums/app/internal/filter"
 	forumModels "github.com/forums/app/internal/forum"
 	postModels "github.com/forums/app/internal/post"
+	reportModels "github.com/forums/app/internal/report"
 	serviceModels "github.com/forums/app/internal/service"
 	threadModels "github.com/forums/app/internal/thread"
 	userModels "github.com/forums/app/internal/user"
 	"github.com/forums/utils/logger"
+	"github.com/forums/utils/prepared"
 
+	filterRepository "github.com/forums/app/internal/filter/repository"
 	forumRepository "github.com/forums/app/internal/forum/repository"
 	postRepository "github.com/forums/app/internal/post/repository"
+	reportRepository "github.com/forums/app/internal/report/repository"
 	serviceRepository "github.com/forums/app/internal/service/repository"
 	threadRepository "github.com/forums/app/internal/thread/repository"
 	userRepository "github.com/forums/app/internal/user/repository"
 
+	filterUsecase "github.com/forums/app/internal/filter/usecase"
 	serviceUsecase "github.com/forums/app/internal/service/usecase"
 
+	filterDelivery "github.com/forums/app/internal/filter/delivery"
 	forumDelivery "github.com/forums/app/internal/forum/delivery"
 	postDelivery "github.com/forums/app/internal/post/delivery"
+	reportDelivery "github.com/forums/app/internal/report/delivery"
 	serviceDelivery "github.com/forums/app/internal/service/delivery"
 	threadDelivery "github.com/forums/app/internal/thread/delivery"
 	userDelivery "github.com/forums/app/internal/user/delivery"
@@ -39,6 +51,8 @@ type Handler struct {
 	post    postModels.PostHandler
 	service serviceModels.ServiceHandler
 	thread  threadModels.ThreadHandler
+	filter  filterModels.FilterHandler
+	report  reportModels.ReportHandler
 }
 
 func newRouter(h Handler) *mux.Router {
@@ -49,21 +63,59 @@ func newRouter(h Handler) *mux.Router {
 	user.HandleFunc("/{nickname}/create", h.user.CreateUser).Methods(http.MethodPost)
 	user.HandleFunc("/{nickname}/profile", h.user.GetUser).Methods(http.MethodGet)
 	user.HandleFunc("/{nickname}/profile", h.user.UpdateUser).Methods(http.MethodPost)
+	user.HandleFunc("/{nickname}/stats", h.user.GetStats).Methods(http.MethodGet)
+	user.HandleFunc("/{nickname}/posts", h.user.GetPosts).Methods(http.MethodGet)
+	user.HandleFunc("/{nickname}/threads", h.user.GetThreads).Methods(http.MethodGet)
+	user.HandleFunc("/{nickname}/rename", h.user.RenameUser).Methods(http.MethodPost)
+	user.HandleFunc("/{nickname}/deactivate", h.user.DeactivateUser).Methods(http.MethodPost)
+	user.HandleFunc("/{nickname}/erase", h.user.EraseUser).Methods(http.MethodPost)
+	user.HandleFunc("/{nickname}/export", h.user.ExportUser).Methods(http.MethodGet)
+	user.HandleFunc("/{nickname}/report", h.report.ReportUser).Methods(http.MethodPost)
+
+	router.HandleFunc("/api/users", h.user.GetUsers).Methods(http.MethodGet)
+	router.HandleFunc("/api/forums", h.forum.GetForums).Methods(http.MethodGet)
+	router.HandleFunc("/api/tags/{tag}/threads", h.thread.GetTagThreads).Methods(http.MethodGet)
 
 	forum := router.PathPrefix("/api/forum").Subrouter()
 	forum.HandleFunc("/create", h.forum.CreateForum).Methods(http.MethodPost)
 	forum.HandleFunc("/{slug}/details", h.forum.GetDetails).Methods(http.MethodGet)
+	forum.HandleFunc("/{slug}/details", h.forum.UpdateDetails).Methods(http.MethodPost)
+	forum.HandleFunc("/{slug}/archive", h.forum.Archive).Methods(http.MethodPost)
+	forum.HandleFunc("/{slug}/rename", h.forum.RenameForum).Methods(http.MethodPost)
+	forum.HandleFunc("/{slug}/roles", h.forum.GetRoles).Methods(http.MethodGet)
+	forum.HandleFunc("/{slug}/roles", h.forum.GrantRole).Methods(http.MethodPost)
+	forum.HandleFunc("/{slug}/roles/{nickname}", h.forum.RevokeRole).Methods(http.MethodDelete)
+	forum.HandleFunc("/{slug}/moderation", h.forum.Moderation).Methods(http.MethodPost)
+	forum.HandleFunc("/{slug}/limits", h.forum.Limits).Methods(http.MethodPost)
+	forum.HandleFunc("/{slug}/queue", h.forum.GetQueue).Methods(http.MethodGet)
+	forum.HandleFunc("/{slug}/queue/{kind}/{id}", h.forum.ResolveQueueItem).Methods(http.MethodPost)
+	forum.HandleFunc("/{slug}/reports", h.report.GetReports).Methods(http.MethodGet)
+	forum.HandleFunc("/{slug}/reports/{id}", h.report.ResolveReport).Methods(http.MethodPost)
+	forum.HandleFunc("/{slug}", h.forum.DeleteForum).Methods(http.MethodDelete)
 	forum.HandleFunc("/{slug}/create", h.thread.CreateThread).Methods(http.MethodPost)
 	forum.HandleFunc("/{slug}/users", h.forum.GetUsers).Methods(http.MethodGet)
 	forum.HandleFunc("/{slug}/threads", h.forum.GetThreads).Methods(http.MethodGet)
+	forum.HandleFunc("/{slug}/tags", h.forum.GetTags).Methods(http.MethodGet)
 
 	post := router.PathPrefix("/api/post").Subrouter()
 	post.HandleFunc("/{id}/details", h.post.GetDetails).Methods(http.MethodGet)
 	post.HandleFunc("/{id}/details", h.post.UpdateDetails).Methods(http.MethodPost)
+	post.HandleFunc("/{id}/report", h.report.ReportPost).Methods(http.MethodPost)
+	post.HandleFunc("/{id}/split", h.post.Split).Methods(http.MethodPost)
+	post.HandleFunc("/{id}/replies", h.post.GetReplies).Methods(http.MethodGet)
+	post.HandleFunc("/{id}/context", h.post.GetContext).Methods(http.MethodGet)
+
+	posts := router.PathPrefix("/api/posts").Subrouter()
+	posts.HandleFunc("/details", h.post.GetManyDetails).Methods(http.MethodPost)
 
 	service := router.PathPrefix("/api/service").Subrouter()
 	service.HandleFunc("/clear", h.service.ClearDb).Methods(http.MethodPost)
 	service.HandleFunc("/status", h.service.StatusDb).Methods(http.MethodGet)
+	service.HandleFunc("/forums_users/resync", h.service.ResyncForumsUsers).Methods(http.MethodPost)
+	service.HandleFunc("/forum/{slug}", h.forum.AdminDeleteForum).Methods(http.MethodDelete)
+	service.HandleFunc("/forum/{slug}/filters", h.filter.GetFilters).Methods(http.MethodGet)
+	service.HandleFunc("/forum/{slug}/filters", h.filter.AddFilter).Methods(http.MethodPost)
+	service.HandleFunc("/forum/{slug}/filters/{id}", h.filter.DeleteFilter).Methods(http.MethodDelete)
 
 	thread := router.PathPrefix("/api/thread").Subrouter()
 	thread.HandleFunc("/{slug_or_id}/create", h.post.CreatePosts).Methods(http.MethodPost)
@@ -71,6 +123,11 @@ func newRouter(h Handler) *mux.Router {
 	thread.HandleFunc("/{slug_or_id}/details", h.thread.UpdateDetails).Methods(http.MethodPost)
 	thread.HandleFunc("/{slug_or_id}/posts", h.thread.GetPosts).Methods(http.MethodGet)
 	thread.HandleFunc("/{slug_or_id}/vote", h.thread.Vote).Methods(http.MethodPost)
+	thread.HandleFunc("/{slug_or_id}/close", h.thread.Close).Methods(http.MethodPost)
+	thread.HandleFunc("/{slug_or_id}/report", h.report.ReportThread).Methods(http.MethodPost)
+	thread.HandleFunc("/{slug_or_id}/read", h.thread.MarkRead).Methods(http.MethodPost)
+	thread.HandleFunc("/{slug_or_id}/move", h.thread.Move).Methods(http.MethodPost)
+	thread.HandleFunc("/{slug_or_id}/merge", h.thread.Merge).Methods(http.MethodPost)
 
 	return router
 }
@@ -84,20 +141,22 @@ func main() {
 	configDB, err := pgx.ParseURI(connectionString)
 	if err != nil {
 		fmt.Println(err)
-		return
+		os.Exit(1)
 	}
 
 	db, err := pgx.NewConnPool(
 		pgx.ConnPoolConfig{
 			ConnConfig:     configDB,
 			MaxConnections: 16,
-			AfterConnect:   nil,
+			AfterConnect:   prepared.AfterConnect,
 			AcquireTimeout: 0,
 		})
 
 	if err != nil {
-		fmt.Println(err)
-		return
+		// сюда же попадает ошибка подготовки запросов на первом соединении:
+		// без них сервер не обслужит ни одного запроса, поэтому падаем с ненулевым кодом
+		logger.Start().AddFuncName("NewConnPool").Error(nil, err)
+		os.Exit(1)
 	}
 
 	userRepo := userRepository.NewUserRepo(db)
@@ -105,14 +164,19 @@ func main() {
 	serviceRepo := serviceRepository.NewServiceRepo(db)
 	postRepo := postRepository.NewPostRepo(db)
 	threadRepo := threadRepository.NewThreadRepo(db)
+	filterRepo := filterRepository.NewFilterRepo(db)
+	reportRepo := reportRepository.NewReportRepo(db)
 
 	serviceUcase := serviceUsecase.NewServiceUsecase(serviceRepo, postRepo)
+	filterUcase := filterUsecase.NewFilterUsecase(filterRepo)
 
-	userHandler := userDelivery.NewUserHandler(userRepo)
+	userHandler := userDelivery.NewUserHandler(userRepo, postRepo, threadRepo, forumRepo)
 	forumHandler := forumDelivery.NewForumHandler(forumRepo, userRepo)
-	postHandler := postDelivery.NewPostHandler(postRepo, userRepo, threadRepo, forumRepo)
+	postHandler := postDelivery.NewPostHandler(postRepo, userRepo, threadRepo, forumRepo, filterUcase)
 	serviceHandler := serviceDelivery.NewServiceHandler(serviceUcase)
-	threadHandler := threadDelivery.NewThreadHandler(threadRepo, userRepo, forumRepo)
+	threadHandler := threadDelivery.NewThreadHandler(threadRepo, userRepo, forumRepo, filterUcase)
+	filterHandler := filterDelivery.NewFilterHandler(filterUcase, forumRepo)
+	reportHandler := reportDelivery.NewReportHandler(reportRepo, forumRepo, postRepo, threadRepo, userRepo)
 
 	handlers := Handler{
 		user:    userHandler,
@@ -120,6 +184,8 @@ func main() {
 		post:    postHandler,
 		service: serviceHandler,
 		thread:  threadHandler,
+		filter:  filterHandler,
+		report:  reportHandler,
 	}
 
 	router := newRouter(handlers)
@@ -129,8 +195,33 @@ func main() {
 		Addr:    ":5000",
 	}
 
+	// просмотры веток копятся в памяти, при остановке их нужно дописать в базу
+	viewsCtx, stopViews := context.WithCancel(context.Background())
+	viewsDone := make(chan struct{})
+	go func() {
+		threadRepo.FlushViewsLoop(viewsCtx)
+		close(viewsDone)
+	}()
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+
+		if err := server.Shutdown(context.Background()); err != nil {
+			logger.Start().AddFuncName("Shutdown").Error(nil, err)
+		}
+		close(shutdownDone)
+	}()
+
 	logger.Start().Error(nil, errors.New("Server starting"))
-	if err := server.ListenAndServe(); err != nil {
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		logger.Start().Error(nil, err)
+	} else {
+		<-shutdownDone
 	}
+
+	stopViews()
+	<-viewsDone
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/forums/app/config"
+	"github.com/forums/utils/prepared"
+	"github.com/jackc/pgx"
+)
+
+// BenchmarkHotQueries сравнивает один и тот же запрос текстом (как было: pgx готовит
+// безымянный запрос на каждый вызов) и по имени подготовленного запроса.
+// Нужна заполненная база: FORUMS_BENCH_DB или параметры из config.
+//
+//	go test ./app/cmd -run NONE -bench HotQueries
+func BenchmarkHotQueries(b *testing.B) {
+	db := benchPool(b)
+	defer db.Close()
+
+	var nickname, slug string
+	var thread int
+	var post int64
+	err := db.QueryRow(`
+		SELECT th.user_create, th.forum, th.id, (SELECT min(p.id) FROM posts as p WHERE p.thread = th.id)
+		FROM threads as th
+		WHERE th.status = 'approved' AND EXISTS (SELECT 1 FROM posts as p WHERE p.thread = th.id)
+		LIMIT 1
+	`).Scan(&nickname, &slug, &thread, &post)
+	if err != nil {
+		b.Skip("no sample thread with posts: ", err)
+	}
+
+	queries := []struct {
+		name string
+		arg  interface{}
+	}{
+		{"user_by_name", nickname},
+		{"forum_by_slug", slug},
+		{"thread_by_id", strconv.Itoa(thread)},
+		{"post_by_id", post},
+	}
+
+	for _, query := range queries {
+		query := query
+		b.Run(query.name+"/text", func(b *testing.B) {
+			runQuery(b, db, prepared.SQL(query.name), query.arg)
+		})
+		b.Run(query.name+"/prepared", func(b *testing.B) {
+			runQuery(b, db, query.name, query.arg)
+		})
+	}
+}
+
+func runQuery(b *testing.B, db *pgx.ConnPool, sql string, arg interface{}) {
+	for i := 0; i < b.N; i++ {
+		rows, err := db.Query(sql, arg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for rows.Next() {
+		}
+		if err := rows.Err(); err != nil {
+			b.Fatal(err)
+		}
+		rows.Close()
+	}
+}
+
+func benchPool(b *testing.B) *pgx.ConnPool {
+	uri := os.Getenv("FORUMS_BENCH_DB")
+	if uri == "" {
+		uri = "postgres://" + config.DBUser + ":" + config.DBPass +
+			"@localhost/" + config.DBName + "?sslmode=disable"
+	}
+
+	configDB, err := pgx.ParseURI(uri)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	db, err := pgx.NewConnPool(pgx.ConnPoolConfig{
+		ConnConfig:     configDB,
+		MaxConnections: 1,
+		AfterConnect:   prepared.AfterConnect,
+	})
+	var pgErr pgx.PgError
+	if errors.As(err, &pgErr) {
+		b.Fatal(err) // база есть, но запрос не готовится
+	}
+	if err != nil {
+		b.Skip("database is not available: ", err)
+	}
+
+	return db
+}
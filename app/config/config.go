@@ -1,8 +1,39 @@
 package config
 
+import (
+	"time"
+
+	"github.com/forums/utils/pagination"
+)
+
 const (
 	PostgresDB = "postgres"
 	DBUser     = "sergei"
 	DBPass     = "1111"
 	DBName     = "forums"
 )
+
+const (
+	NicknameAliasTTL   = 30 * 24 * time.Hour // сколько старый никнейм редиректит на новый
+	ViewsFlushInterval = 5 * time.Second     // как часто накопленные просмотры веток пишутся в базу
+	FilterCacheTTL     = time.Minute         // сколько живут закэшированные правила фильтров форума
+)
+
+const MaxPostsDetails = 100 // сколько постов можно запросить одним POST /api/posts/details
+
+// размеры страниц списков, limit больше Max отклоняется с 400.
+// Для списков из исходного api.yml Max совпадает с его maximum: 10000
+var (
+	ThreadPostsPage  = pagination.Limits{Default: 100, Max: 10000}
+	ForumThreadsPage = pagination.Limits{Default: 100, Max: 10000}
+	ForumUsersPage   = pagination.Limits{Default: 100, Max: 10000}
+	TagThreadsPage   = pagination.Limits{Default: 100, Max: 1000}
+	TagCloudPage     = pagination.Limits{Default: 50, Max: 500}
+	PostRepliesPage  = pagination.Limits{Default: 100, Max: 1000}
+	UsersPage        = pagination.Limits{Default: 100, Max: 1000}
+	UserPostsPage    = pagination.Limits{Default: 100, Max: 1000}
+	UserThreadsPage  = pagination.Limits{Default: 100, Max: 1000}
+	ReportsPage      = pagination.Limits{Default: 50, Max: 500}
+	QueuePage        = pagination.Limits{Default: 50, Max: 500}
+	UserExportPage   = pagination.Limits{Default: 1000, Max: 10000}
+)
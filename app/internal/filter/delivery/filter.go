@@ -0,0 +1,138 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	filterModel "github.com/forums/app/internal/filter"
+	forumModel "github.com/forums/app/internal/forum"
+	"github.com/forums/app/models"
+	"github.com/forums/utils/errors"
+	"github.com/forums/utils/logger"
+	"github.com/forums/utils/response"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	filterUsecase filterModel.FilterUsecase
+	forumRepo     forumModel.ForumRepo
+}
+
+func NewFilterHandler(filterUsecase filterModel.FilterUsecase, forumRepo forumModel.ForumRepo) filterModel.FilterHandler {
+	return &Handler{
+		filterUsecase: filterUsecase,
+		forumRepo:     forumRepo,
+	}
+}
+
+func (h *Handler) getForum(w http.ResponseWriter, r *http.Request) *models.Forum {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+
+	forum, err := h.forumRepo.GetForumBySlug(ctx, slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil
+	}
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + slug + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return nil
+	}
+
+	return forum
+}
+
+func (h *Handler) GetFilters(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	forum := h.getForum(w, r)
+	if forum == nil {
+		return
+	}
+	logger.Delivery().Info(ctx, logger.Fields{"request data": forum.Slug})
+
+	rules, err := h.filterUsecase.GetFilters(ctx, forum.Slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, rules).SendSuccess(w)
+}
+
+func (h *Handler) AddFilter(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rule := new(models.FilterRule)
+	err := json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *rule})
+
+	forum := h.getForum(w, r)
+	if forum == nil {
+		return
+	}
+
+	rule.Forum = forum.Slug
+	err = h.filterUsecase.AddFilter(ctx, rule)
+	if err != nil {
+		if custErr, ok := err.(errors.Error); ok {
+			message := models.Message{
+				Message: custErr.Error() + "\n",
+			}
+			response.New(custErr.Code(), message).SendSuccess(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusCreated, rule).SendSuccess(w)
+}
+
+func (h *Handler) DeleteFilter(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	logger.Delivery().Info(ctx, logger.Fields{"request data": id})
+
+	forum := h.getForum(w, r)
+	if forum == nil {
+		return
+	}
+
+	found, err := h.filterUsecase.DeleteFilter(ctx, forum.Slug, id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		message := models.Message{
+			Message: "Can't find filter with id #" + vars["id"] + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
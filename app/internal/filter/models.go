@@ -0,0 +1,39 @@
+package filter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/forums/app/models"
+)
+
+type FilterHandler interface {
+	GetFilters(w http.ResponseWriter, r *http.Request)
+	AddFilter(w http.ResponseWriter, r *http.Request)
+	DeleteFilter(w http.ResponseWriter, r *http.Request)
+}
+
+type FilterUsecase interface {
+	Check(ctx context.Context, contents []*models.FilterContent) (*models.FilterRejection, error)
+	GetFilters(ctx context.Context, slug string) (*[]models.FilterRule, error)
+	AddFilter(ctx context.Context, rule *models.FilterRule) error
+	DeleteFilter(ctx context.Context, slug string, id int) (bool, error)
+}
+
+type FilterRepo interface {
+	GetFilters(ctx context.Context, slug string) (*[]models.FilterRule, error)
+	AddFilter(ctx context.Context, rule *models.FilterRule) (int, error)
+	DeleteFilter(ctx context.Context, slug string, id int) (bool, error)
+	HasDuplicate(ctx context.Context, content *models.FilterContent, windowMinutes int) (bool, error)
+}
+
+// Filter - одно правило цепочки: Match проверяет текст, Mask пытается его исправить
+type Filter interface {
+	Match(ctx context.Context, content *models.FilterContent) (bool, error)
+	Mask(content *models.FilterContent) bool
+}
+
+// BatchFilter - правило, которому нужны тексты, проверенные раньше в том же запросе
+type BatchFilter interface {
+	MatchEarlier(content *models.FilterContent, earlier []*models.FilterContent) bool
+}
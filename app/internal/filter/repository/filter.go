@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+
+	filterModel "github.com/forums/app/internal/filter"
+	"github.com/forums/app/models"
+	"github.com/forums/utils/logger"
+	"github.com/jackc/pgx"
+)
+
+type repo struct {
+	DB *pgx.ConnPool
+}
+
+func NewFilterRepo(db *pgx.ConnPool) filterModel.FilterRepo {
+	return &repo{
+		DB: db,
+	}
+}
+
+func (r *repo) GetFilters(ctx context.Context, slug string) (*[]models.FilterRule, error) {
+	query :=
+		`
+		SELECT ff.id, ff.forum, ff.kind, ff.action, ff.pattern, ff.lim
+		FROM forums_filters as ff
+		WHERE ff.forum = $1
+		ORDER BY ff.id
+	`
+
+	rulesDB, err := r.DB.Query(query, slug)
+	if err != nil {
+		logger.Repo().AddFuncName("GetFilters").Error(ctx, err)
+		return nil, err
+	}
+	defer rulesDB.Close()
+
+	rules := make([]models.FilterRule, 0)
+	for rulesDB.Next() {
+		rule := new(models.FilterRule)
+		err := rulesDB.Scan(
+			&rule.Id,
+			&rule.Forum,
+			&rule.Kind,
+			&rule.Action,
+			&rule.Pattern,
+			&rule.Limit,
+		)
+
+		if err != nil {
+			logger.Repo().AddFuncName("GetFilters").Error(ctx, err)
+			return nil, err
+		}
+
+		rules = append(rules, *rule)
+	}
+
+	return &rules, nil
+}
+
+func (r *repo) AddFilter(ctx context.Context, rule *models.FilterRule) (id int, err error) {
+	query :=
+		`
+		INSERT INTO forums_filters (forum, kind, action, pattern, lim)
+		VALUES ($1, $2, $3, $4, $5) returning id
+	`
+
+	err = r.DB.QueryRow(query,
+		rule.Forum,
+		rule.Kind,
+		rule.Action,
+		rule.Pattern,
+		rule.Limit).Scan(&id)
+
+	if err != nil {
+		logger.Repo().AddFuncName("AddFilter").Error(ctx, err)
+		return 0, err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"filter id": id})
+	return id, nil
+}
+
+func (r *repo) DeleteFilter(ctx context.Context, slug string, id int) (bool, error) {
+	query :=
+		`
+		DELETE FROM forums_filters
+		WHERE id = $1 AND forum = $2
+	`
+
+	result, err := r.DB.Exec(query, id, slug)
+	if err != nil {
+		logger.Repo().AddFuncName("DeleteFilter").Error(ctx, err)
+		return false, err
+	}
+
+	return result.RowsAffected() != 0, nil
+}
+
+func (r *repo) HasDuplicate(ctx context.Context, content *models.FilterContent, windowMinutes int) (bool, error) {
+	query :=
+		`
+		SELECT EXISTS (
+			SELECT 1 FROM posts as p
+			WHERE p.user_create = $1 AND p.forum = $2 AND p.message = $3 AND p.id <> $4
+			AND ($5 = 0 OR p.created > now() - make_interval(mins => $5))
+		)
+	`
+	if content.Thread {
+		query =
+			`
+			SELECT EXISTS (
+				SELECT 1 FROM threads as th
+				WHERE th.user_create = $1 AND th.forum = $2 AND th.message = $3 AND th.id <> $4
+				AND ($5 = 0 OR th.created > now() - make_interval(mins => $5))
+			)
+		`
+	}
+
+	var exists bool
+	err := r.DB.QueryRow(query,
+		content.Author,
+		content.Forum,
+		content.Message,
+		content.Id,
+		windowMinutes).Scan(&exists)
+
+	if err != nil {
+		logger.Repo().AddFuncName("HasDuplicate").Error(ctx, err)
+		return false, err
+	}
+
+	return exists, nil
+}
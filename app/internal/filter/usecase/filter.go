@@ -0,0 +1,324 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/forums/app/config"
+	filterModel "github.com/forums/app/internal/filter"
+	"github.com/forums/app/models"
+	custErrors "github.com/forums/utils/errors"
+)
+
+// Builder собирает фильтр из правила форума
+type Builder func(rule *models.FilterRule, repo filterModel.FilterRepo) (filterModel.Filter, error)
+
+var builders = map[string]Builder{
+	models.FilterWords:     newWordsFilter,
+	models.FilterLinks:     newLinksFilter,
+	models.FilterLength:    newLengthFilter,
+	models.FilterDuplicate: newDuplicateFilter,
+}
+
+// RegisterFilter добавляет новый вид фильтра, kind должен быть разрешён в forums_filters
+func RegisterFilter(kind string, builder Builder) {
+	builders[kind] = builder
+}
+
+type usecase struct {
+	filterRepo filterModel.FilterRepo
+
+	// собранные цепочки по форумам, сбрасываются при правке правил и по FilterCacheTTL
+	mutex  sync.RWMutex
+	chains map[string]cachedChain
+}
+
+func NewFilterUsecase(filterRepo filterModel.FilterRepo) filterModel.FilterUsecase {
+	return &usecase{
+		filterRepo: filterRepo,
+		chains:     make(map[string]cachedChain),
+	}
+}
+
+type chainItem struct {
+	rule   models.FilterRule
+	filter filterModel.Filter
+}
+
+type cachedChain struct {
+	items  []chainItem
+	loaded time.Time
+}
+
+func (u *usecase) build(rule *models.FilterRule) (filterModel.Filter, error) {
+	builder, ok := builders[rule.Kind]
+	if !ok {
+		return nil, errors.New("unknown filter kind " + rule.Kind)
+	}
+
+	return builder(rule, u.filterRepo)
+}
+
+// slug в форумах - citext
+func cacheKey(slug string) string {
+	return strings.ToLower(slug)
+}
+
+func (u *usecase) forget(slug string) {
+	u.mutex.Lock()
+	delete(u.chains, cacheKey(slug))
+	u.mutex.Unlock()
+}
+
+func (u *usecase) chain(ctx context.Context, slug string) ([]chainItem, error) {
+	u.mutex.RLock()
+	cached, ok := u.chains[cacheKey(slug)]
+	u.mutex.RUnlock()
+	if ok && time.Since(cached.loaded) < config.FilterCacheTTL {
+		return cached.items, nil
+	}
+
+	loaded := time.Now()
+	chain, err := u.loadChain(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mutex.Lock()
+	u.chains[cacheKey(slug)] = cachedChain{items: chain, loaded: loaded}
+	u.mutex.Unlock()
+
+	return chain, nil
+}
+
+func (u *usecase) loadChain(ctx context.Context, slug string) ([]chainItem, error) {
+	rules, err := u.filterRepo.GetFilters(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]chainItem, 0, len(*rules))
+	for _, rule := range *rules {
+		filter, err := u.build(&rule)
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, chainItem{rule: rule, filter: filter})
+	}
+
+	return chain, nil
+}
+
+func (u *usecase) Check(ctx context.Context, contents []*models.FilterContent) (*models.FilterRejection, error) {
+	if len(contents) == 0 {
+		return nil, nil
+	}
+
+	// все тексты из одного запроса относятся к одному форуму
+	chain, err := u.chain(ctx, contents[0].Forum)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, content := range contents {
+		for _, item := range chain {
+			matched, err := item.filter.Match(ctx, content)
+			if err != nil {
+				return nil, err
+			}
+			if batch, ok := item.filter.(filterModel.BatchFilter); ok && !matched {
+				matched = batch.MatchEarlier(content, contents[:i])
+			}
+			if !matched {
+				continue
+			}
+
+			action := item.rule.Action
+			if action == models.ActionMask && !item.filter.Mask(content) {
+				action = models.ActionReject
+			}
+			// правку уже опубликованного текста нельзя отправить в очередь модерации
+			if action == models.ActionQueue && content.Edit {
+				action = models.ActionReject
+			}
+
+			switch action {
+			case models.ActionReject:
+				return &models.FilterRejection{
+					Kind:    item.rule.Kind,
+					Message: "Message rejected by " + item.rule.Kind + " filter\n",
+				}, nil
+			case models.ActionQueue:
+				content.Queued = true
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func (u *usecase) GetFilters(ctx context.Context, slug string) (*[]models.FilterRule, error) {
+	return u.filterRepo.GetFilters(ctx, slug)
+}
+
+func (u *usecase) AddFilter(ctx context.Context, rule *models.FilterRule) error {
+	switch rule.Action {
+	case models.ActionReject, models.ActionMask, models.ActionQueue:
+	default:
+		return custErrors.New(http.StatusBadRequest, "unknown filter action "+rule.Action)
+	}
+
+	if _, err := u.build(rule); err != nil {
+		return custErrors.New(http.StatusBadRequest, err.Error())
+	}
+
+	id, err := u.filterRepo.AddFilter(ctx, rule)
+	if err != nil {
+		return err
+	}
+
+	rule.Id = id
+	u.forget(rule.Forum)
+	return nil
+}
+
+func (u *usecase) DeleteFilter(ctx context.Context, slug string, id int) (bool, error) {
+	found, err := u.filterRepo.DeleteFilter(ctx, slug, id)
+	if found {
+		u.forget(slug)
+	}
+
+	return found, err
+}
+
+type wordsFilter struct {
+	re *regexp.Regexp
+}
+
+func newWordsFilter(rule *models.FilterRule, repo filterModel.FilterRepo) (filterModel.Filter, error) {
+	if rule.Pattern == "" {
+		return nil, errors.New("words filter needs pattern")
+	}
+
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wordsFilter{re: re}, nil
+}
+
+func (f *wordsFilter) Match(ctx context.Context, content *models.FilterContent) (bool, error) {
+	return f.re.MatchString(content.Title) || f.re.MatchString(content.Message), nil
+}
+
+func (f *wordsFilter) Mask(content *models.FilterContent) bool {
+	mask := func(word string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	}
+
+	content.Title = f.re.ReplaceAllStringFunc(content.Title, mask)
+	content.Message = f.re.ReplaceAllStringFunc(content.Message, mask)
+	return true
+}
+
+var linkRe = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+type linksFilter struct {
+	limit int
+}
+
+func newLinksFilter(rule *models.FilterRule, repo filterModel.FilterRepo) (filterModel.Filter, error) {
+	if rule.Limit < 0 {
+		return nil, errors.New("links filter limit must not be negative")
+	}
+
+	return &linksFilter{limit: rule.Limit}, nil
+}
+
+func (f *linksFilter) Match(ctx context.Context, content *models.FilterContent) (bool, error) {
+	return len(linkRe.FindAllStringIndex(content.Message, f.limit+1)) > f.limit, nil
+}
+
+func (f *linksFilter) Mask(content *models.FilterContent) bool {
+	found := 0
+	content.Message = linkRe.ReplaceAllStringFunc(content.Message, func(link string) string {
+		found++
+		if found <= f.limit {
+			return link
+		}
+		return "[link removed]"
+	})
+
+	return true
+}
+
+type lengthFilter struct {
+	limit int
+}
+
+func newLengthFilter(rule *models.FilterRule, repo filterModel.FilterRepo) (filterModel.Filter, error) {
+	if rule.Limit <= 0 {
+		return nil, errors.New("length filter needs positive limit")
+	}
+
+	return &lengthFilter{limit: rule.Limit}, nil
+}
+
+func (f *lengthFilter) Match(ctx context.Context, content *models.FilterContent) (bool, error) {
+	return utf8.RuneCountInString(content.Title) > f.limit || utf8.RuneCountInString(content.Message) > f.limit, nil
+}
+
+func (f *lengthFilter) Mask(content *models.FilterContent) bool {
+	content.Title = f.cut(content.Title)
+	content.Message = f.cut(content.Message)
+	return true
+}
+
+func (f *lengthFilter) cut(text string) string {
+	if utf8.RuneCountInString(text) <= f.limit {
+		return text
+	}
+
+	return string([]rune(text)[:f.limit])
+}
+
+type duplicateFilter struct {
+	repo          filterModel.FilterRepo
+	windowMinutes int
+}
+
+func newDuplicateFilter(rule *models.FilterRule, repo filterModel.FilterRepo) (filterModel.Filter, error) {
+	if rule.Limit < 0 {
+		return nil, errors.New("duplicate filter window must not be negative")
+	}
+
+	return &duplicateFilter{repo: repo, windowMinutes: rule.Limit}, nil
+}
+
+func (f *duplicateFilter) Match(ctx context.Context, content *models.FilterContent) (bool, error) {
+	return f.repo.HasDuplicate(ctx, content, f.windowMinutes)
+}
+
+// повтор внутри одного запроса в базе ещё не виден
+func (f *duplicateFilter) MatchEarlier(content *models.FilterContent, earlier []*models.FilterContent) bool {
+	for _, other := range earlier {
+		if strings.EqualFold(other.Author, content.Author) && other.Message == content.Message {
+			return true
+		}
+	}
+
+	return false
+}
+
+// повтор нечем замаскировать, поэтому mask для него работает как reject
+func (f *duplicateFilter) Mask(content *models.FilterContent) bool {
+	return false
+}
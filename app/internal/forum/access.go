@@ -0,0 +1,85 @@
+package forum
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/response"
+	"github.com/gorilla/mux"
+)
+
+// Проверки прав, общие для всех delivery. При отказе они сами пишут ответ.
+
+// CheckModerator пишет 403, если actor не модератор форума slug
+func CheckModerator(w http.ResponseWriter, r *http.Request, forumRepo ForumRepo, slug, actor string) bool {
+	role, err := forumRepo.GetRole(r.Context(), slug, actor)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	if !models.IsModerator(role) {
+		message := models.Message{
+			Message: "User #" + actor + " can't moderate forum #" + slug + "\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return false
+	}
+
+	return true
+}
+
+// ModeratedForum возвращает форум {slug}, если user из запроса его модератор
+func ModeratedForum(w http.ResponseWriter, r *http.Request, forumRepo ForumRepo) *models.Forum {
+	slug := mux.Vars(r)["slug"]
+
+	forum, err := forumRepo.GetForumBySlug(r.Context(), slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil
+	}
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + slug + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return nil
+	}
+
+	if !CheckModerator(w, r, forumRepo, forum.Slug, r.URL.Query().Get("user")) {
+		return nil
+	}
+
+	return forum
+}
+
+// CheckEditor пускает к правке автора и модераторов форума, забаненным правка закрыта.
+// Без actor правка остаётся анонимной, как в исходном API.
+// what - что правят, для текста ошибки: "post #1", "thread #2".
+func CheckEditor(w http.ResponseWriter, r *http.Request, forumRepo ForumRepo, slug, author, actor, what string) bool {
+	if actor == "" {
+		return true
+	}
+
+	role, err := forumRepo.GetRole(r.Context(), slug, actor)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	if role == models.RoleBanned {
+		message := models.Message{
+			Message: "User #" + actor + " is banned in forum #" + slug + "\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return false
+	}
+	if !strings.EqualFold(actor, author) && !models.IsModerator(role) {
+		message := models.Message{
+			Message: "User #" + actor + " can't edit " + what + "\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return false
+	}
+
+	return true
+}
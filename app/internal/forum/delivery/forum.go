@@ -4,14 +4,19 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/forums/app/config"
 	forumModel "github.com/forums/app/internal/forum"
 	userModel "github.com/forums/app/internal/user"
 	"github.com/forums/app/models"
 	"github.com/forums/utils/errors"
 	"github.com/forums/utils/logger"
+	"github.com/forums/utils/pagination"
 	"github.com/forums/utils/response"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx"
 )
 
 type Handler struct {
@@ -38,7 +43,7 @@ func (h *Handler) CreateForum(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	logger.Delivery().Info(ctx, logger.Fields{"request data": *newForum})
 
-	forumDb, err := h.forumRepo.GetForumBySlug(ctx, newForum.Slug)
+	forumDb, err := h.forumRepo.GetForum(ctx, newForum.Slug)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -57,7 +62,35 @@ func (h *Handler) CreateForum(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if newForum.Parent != nil {
+		parent, err := h.forumRepo.GetForumBySlug(ctx, *newForum.Parent)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if parent == nil {
+			message := models.Message{
+				Message: "Can't find forum with id #" + *newForum.Parent + "\n",
+			}
+			response.New(http.StatusNotFound, message).SendSuccess(w)
+			return
+		}
+
+		newForum.Parent = &parent.Slug
+		if newForum.Category == "" {
+			newForum.Category = parent.Category
+		}
+	}
+
 	newForum.User = user.Nickname
+	if !newForum.ForumLimits.Validate() {
+		message := models.Message{
+			Message: "Invalid forum limits\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
 	_, err = h.forumRepo.CreateForum(ctx, newForum)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -87,6 +120,16 @@ func (h *Handler) GetDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	children := r.URL.Query().Get("children")
+	if children != "false" && children != "" {
+		forums, err := h.forumRepo.GetChildren(ctx, forum.Slug)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		forum.Children = *forums
+	}
+
 	response.New(http.StatusOK, forum).SendSuccess(w)
 }
 
@@ -97,15 +140,14 @@ func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	forumUsers.Slug = vars["slug"]
-	forumUsers.Limit = r.URL.Query().Get("limit")
-
-	forumUsers.Since = r.URL.Query().Get("since")
-	desc := r.URL.Query().Get("desc")
-	if desc == "false" || desc == "" {
-		forumUsers.Desc = false
-	} else {
-		forumUsers.Desc = true
+	page, pageErr := pagination.Parse(r.URL.Query(), config.ForumUsersPage, pagination.SinceString)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
 	}
+	forumUsers.Limit = page.Limit
+	forumUsers.Since = page.Since
+	forumUsers.Desc = page.Desc
 
 	logger.Delivery().Info(ctx, logger.Fields{"request data": *forumUsers})
 
@@ -123,64 +165,716 @@ func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := h.forumRepo.GetUsers(ctx, forumUsers)
+	forumUsers.Slug = forum.Slug
+	stream := response.NewStream(w, http.StatusOK)
+	err = h.forumRepo.GetUsers(ctx, forumUsers, func(user *models.User) error {
+		return stream.Write(user)
+	})
+	stream.End(err)
+}
+
+func (h *Handler) GetThreads(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	forumThreads := new(models.ForumThreads)
+
+	vars := mux.Vars(r)
+	forumThreads.Slug = vars["slug"]
+	forumThreads.Tag = strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag")))
+	forumThreads.Sort = r.URL.Query().Get("sort")
+	forumThreads.Viewer = r.URL.Query().Get("viewer")
+	since := pagination.SinceTime
+	switch forumThreads.Sort {
+	case "", models.SortCreated:
+	case models.SortVotes, models.SortActivity, models.SortReplies, models.SortHot:
+		// для этих сортировок since - id последней ветки предыдущей страницы
+		since = pagination.SinceId
+	default:
+		message := models.Message{
+			Message: "Unknown sort #" + forumThreads.Sort + "\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
+	page, pageErr := pagination.Parse(r.URL.Query(), config.ForumThreadsPage, since)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+	forumThreads.Limit = page.Limit
+	forumThreads.Since = page.Since
+	forumThreads.Desc = page.Desc
+
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *forumThreads})
+
+	forum, err := h.forumRepo.GetForumBySlug(ctx, forumThreads.Slug)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	response.New(http.StatusOK, users).SendSuccess(w)
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + forumThreads.Slug + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	forumThreads.Slug = forum.Slug
+	stream := response.NewStream(w, http.StatusOK)
+	err = h.forumRepo.GetThreads(ctx, forumThreads, func(thread *models.Thread) error {
+		return stream.Write(thread)
+	})
+	stream.End(err)
 }
 
-func (h *Handler) GetThreads(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateDetails(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	forumThreads := new(models.ForumThreads)
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	newForum := new(models.Forum)
+	err := json.NewDecoder(r.Body).Decode(&newForum)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *newForum, "slug": slug})
+
+	forum := forumModel.ModeratedForum(w, r, h.forumRepo)
+	if forum == nil {
+		return
+	}
+
+	if newForum.Title != "" {
+		forum.Title = newForum.Title
+	}
+
+	if newForum.Description != "" {
+		forum.Description = newForum.Description
+	}
+
+	err = h.forumRepo.UpdateForum(ctx, forum)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, forum).SendSuccess(w)
+}
+
+func (h *Handler) Archive(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
 	vars := mux.Vars(r)
-	forumThreads.Slug = vars["slug"]
-	limit := r.URL.Query().Get("limit")
-	if limit != "" {
-		limitConv, err := strconv.Atoi(limit)
+	slug := vars["slug"]
+	archive := new(models.ArchiveForumRequest)
+	err := json.NewDecoder(r.Body).Decode(&archive)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *archive, "slug": slug})
+
+	forum := forumModel.ModeratedForum(w, r, h.forumRepo)
+	if forum == nil {
+		return
+	}
+
+	err = h.forumRepo.SetArchived(ctx, forum.Slug, archive.Archived)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	forum.Archived = archive.Archived
+	response.New(http.StatusOK, forum).SendSuccess(w)
+}
+
+func (h *Handler) deleteForum(w http.ResponseWriter, r *http.Request, checkOwner bool) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	nickname := r.URL.Query().Get("user")
+	logger.Delivery().Info(ctx, logger.Fields{"request data": slug, "user": nickname})
+
+	forum, err := h.forumRepo.GetForumBySlug(ctx, slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + slug + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	if checkOwner && !strings.EqualFold(forum.User, nickname) {
+		message := models.Message{
+			Message: "Only owner can delete forum #" + slug + "\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	err = h.forumRepo.DeleteForum(ctx, forum.Slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, forum).SendSuccess(w)
+}
+
+func (h *Handler) DeleteForum(w http.ResponseWriter, r *http.Request) {
+	h.deleteForum(w, r, true)
+}
+
+func (h *Handler) AdminDeleteForum(w http.ResponseWriter, r *http.Request) {
+	h.deleteForum(w, r, false)
+}
+
+func (h *Handler) RenameForum(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	rename := new(models.RenameForumRequest)
+	err := json.NewDecoder(r.Body).Decode(&rename)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *rename, "slug": slug, "user": r.URL.Query().Get("user")})
+
+	if rename.Slug == "" {
+		message := models.Message{
+			Message: "New slug is empty\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
+	forum, err := h.forumRepo.GetForumBySlug(ctx, slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + slug + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	// переименование каскадом правит ветки, посты и алиасы, поэтому только владельцу
+	nickname := r.URL.Query().Get("user")
+	role, err := h.forumRepo.GetRole(ctx, forum.Slug, nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if role != models.RoleOwner {
+		message := models.Message{
+			Message: "Only owner can rename forum #" + forum.Slug + "\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	forumDb, err := h.forumRepo.GetForum(ctx, rename.Slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forumDb != nil && forumDb.Slug != forum.Slug {
+		response.New(http.StatusConflict, forumDb).SendSuccess(w)
+		return
+	}
+
+	err = h.forumRepo.RenameForum(ctx, forum.Slug, rename.Slug)
+	if err != nil {
+		if pqErr, ok := err.(pgx.PgError); ok && pqErr.Code == pgerrcode.UniqueViolation {
+			message := models.Message{
+				Message: "Slug #" + rename.Slug + " is already taken\n",
+			}
+			response.New(http.StatusConflict, message).SendSuccess(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	forum.Slug = rename.Slug
+	response.New(http.StatusOK, forum).SendSuccess(w)
+}
+
+func (h *Handler) buildTree(children map[string][]models.Forum, parent string) []models.Forum {
+	tree := make([]models.Forum, 0, len(children[parent]))
+	for _, forum := range children[parent] {
+		forum.Children = h.buildTree(children, strings.ToLower(forum.Slug))
+		tree = append(tree, forum)
+	}
+
+	return tree
+}
+
+func (h *Handler) GetForums(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	category := r.URL.Query().Get("category")
+	logger.Delivery().Info(ctx, logger.Fields{"request data": category})
+
+	forums, err := h.forumRepo.GetForums(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	children := make(map[string][]models.Forum)
+	for _, forum := range *forums {
+		if forum.Parent != nil {
+			parent := strings.ToLower(*forum.Parent)
+			children[parent] = append(children[parent], forum)
+		}
+	}
+
+	categories := make([]models.ForumCategory, 0)
+	for _, forum := range *forums {
+		if forum.Parent != nil {
+			continue
+		}
+		if category != "" && forum.Category != category {
+			continue
+		}
+
+		if len(categories) == 0 || categories[len(categories)-1].Category != forum.Category {
+			categories = append(categories, models.ForumCategory{
+				Category: forum.Category,
+				Forums:   make([]models.Forum, 0),
+			})
+		}
+
+		forum.Children = h.buildTree(children, strings.ToLower(forum.Slug))
+
+		last := &categories[len(categories)-1]
+		last.Forums = append(last.Forums, forum)
+		last.TotalThreads += forum.TotalThreads
+		last.TotalPosts += forum.TotalPosts
+	}
+
+	response.New(http.StatusOK, categories).SendSuccess(w)
+}
+
+func (h *Handler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	logger.Delivery().Info(ctx, logger.Fields{"request data": slug})
+
+	forum, err := h.forumRepo.GetForumBySlug(ctx, slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + slug + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	roles, err := h.forumRepo.GetRoles(ctx, forum.Slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, roles).SendSuccess(w)
+}
+
+// модератор может только принимать и банить участников, остальное доступно владельцу
+func (h *Handler) canManageRole(actorRole, targetRole, newRole string) bool {
+	if actorRole == models.RoleOwner {
+		return true
+	}
+	if actorRole != models.RoleModerator {
+		return false
+	}
+
+	return !models.IsModerator(targetRole) && !models.IsModerator(newRole)
+}
+
+func (h *Handler) changeRole(w http.ResponseWriter, r *http.Request, forumRole *models.ForumRole) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	actor := r.URL.Query().Get("user")
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *forumRole, "slug": slug, "user": actor})
+
+	forum, err := h.forumRepo.GetForumBySlug(ctx, slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + slug + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	user, err := h.userRepo.GetUserByName(ctx, forumRole.Nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		message := models.Message{
+			Message: "Can't find user with id #" + forumRole.Nickname + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	if strings.EqualFold(forum.User, user.Nickname) {
+		message := models.Message{
+			Message: "Can't change role of forum creator\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	actorRole, err := h.forumRepo.GetRole(ctx, forum.Slug, actor)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	targetRole, err := h.forumRepo.GetRole(ctx, forum.Slug, user.Nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !h.canManageRole(actorRole, targetRole, forumRole.Role) {
+		message := models.Message{
+			Message: "User #" + actor + " can't manage roles in forum #" + forum.Slug + "\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	forumRole.Forum = forum.Slug
+	forumRole.Nickname = user.Nickname
+	if forumRole.Role == "" {
+		err = h.forumRepo.DeleteRole(ctx, forumRole.Forum, forumRole.Nickname)
+	} else {
+		err = h.forumRepo.SetRole(ctx, forumRole)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, forumRole).SendSuccess(w)
+}
+
+func (h *Handler) GrantRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	forumRole := new(models.ForumRole)
+	err := json.NewDecoder(r.Body).Decode(&forumRole)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+
+	switch forumRole.Role {
+	case models.RoleOwner, models.RoleModerator, models.RoleMember, models.RoleBanned:
+	default:
+		message := models.Message{
+			Message: "Unknown role #" + forumRole.Role + "\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
+	h.changeRole(w, r, forumRole)
+}
+
+func (h *Handler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	forumRole := &models.ForumRole{
+		Nickname: vars["nickname"],
+	}
+
+	h.changeRole(w, r, forumRole)
+}
+
+func (h *Handler) Moderation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	moderation := new(models.ModerationForumRequest)
+	err := json.NewDecoder(r.Body).Decode(&moderation)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *moderation})
+
+	forum := forumModel.ModeratedForum(w, r, h.forumRepo)
+	if forum == nil {
+		return
+	}
+
+	err = h.forumRepo.SetModeration(ctx, forum.Slug, moderation.Moderation)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	forum.Moderation = moderation.Moderation
+	response.New(http.StatusOK, forum).SendSuccess(w)
+}
+
+func (h *Handler) Limits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	limits := new(models.ForumLimits)
+	err := json.NewDecoder(r.Body).Decode(&limits)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *limits})
+
+	if !limits.Validate() {
+		message := models.Message{
+			Message: "Invalid forum limits\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
+	forum := forumModel.ModeratedForum(w, r, h.forumRepo)
+	if forum == nil {
+		return
+	}
+
+	err = h.forumRepo.SetLimits(ctx, forum.Slug, limits)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	forum.ForumLimits = *limits
+	response.New(http.StatusOK, forum).SendSuccess(w)
+}
+
+func (h *Handler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	page, pageErr := pagination.Parse(query, config.QueuePage, pagination.SinceId)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+
+	// since - id из одной очереди, поэтому листать дальше первой страницы можно только с kind
+	queuePage := &models.ForumQueuePage{
+		Kind:  query.Get("kind"),
+		Limit: page.Limit,
+		Since: page.Since,
+		Desc:  page.Desc,
+	}
+	switch {
+	case queuePage.Kind != "" && queuePage.Kind != "threads" && queuePage.Kind != "posts":
+		message := models.Message{
+			Message: "Unknown queue #" + queuePage.Kind + "\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	case queuePage.Kind == "" && queuePage.Since != "":
+		message := models.Message{
+			Message: "Since requires queue kind\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
+	forum := forumModel.ModeratedForum(w, r, h.forumRepo)
+	if forum == nil {
+		return
+	}
+	queuePage.Slug = forum.Slug
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *queuePage})
+
+	queue := models.ForumQueue{
+		Threads: &[]models.Thread{},
+		Posts:   &[]models.Post{},
+	}
+	var err error
+
+	if queuePage.Kind != "posts" {
+		queue.Threads, err = h.forumRepo.GetPendingThreads(ctx, queuePage)
 		if err != nil {
-			sendErr := errors.New(http.StatusBadRequest, "convert request data - limit")
-			logger.Delivery().Error(ctx, sendErr)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+	}
 
-		forumThreads.Limit = limitConv
+	if queuePage.Kind != "threads" {
+		queue.Posts, err = h.forumRepo.GetPendingPosts(ctx, queuePage)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 	}
 
-	forumThreads.Since = r.URL.Query().Get("since")
-	desc := r.URL.Query().Get("desc")
-	if desc == "false" || desc == "" {
-		forumThreads.Desc = false
-	} else {
-		forumThreads.Desc = true
+	response.New(http.StatusOK, queue).SendSuccess(w)
+}
+
+func (h *Handler) ResolveQueueItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	kind := vars["kind"]
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
 	}
 
-	logger.Delivery().Info(ctx, logger.Fields{"request data": *forumThreads})
+	action := new(models.QueueActionRequest)
+	err = json.NewDecoder(r.Body).Decode(&action)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *action, "kind": kind, "id": id})
+
+	var status string
+	switch action.Action {
+	case "approve":
+		status = models.StatusApproved
+	case "reject":
+		status = models.StatusRejected
+	default:
+		message := models.Message{
+			Message: "Unknown action #" + action.Action + "\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
 
-	forum, err := h.forumRepo.GetForumBySlug(ctx, forumThreads.Slug)
+	forum := forumModel.ModeratedForum(w, r, h.forumRepo)
+	if forum == nil {
+		return
+	}
+
+	// в ответ уходит решённый элемент, item остаётся nil, если его нет в очереди
+	var item interface{}
+	switch kind {
+	case "threads":
+		var thread *models.Thread
+		thread, err = h.forumRepo.SetThreadStatus(ctx, forum.Slug, id, status)
+		if thread != nil {
+			item = thread
+		}
+	case "posts":
+		var post *models.Post
+		post, err = h.forumRepo.SetPostStatus(ctx, forum.Slug, id, status)
+		if post != nil {
+			item = post
+		}
+	default:
+		message := models.Message{
+			Message: "Unknown queue #" + kind + "\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if item == nil {
+		message := models.Message{
+			Message: "Can't find pending item with id #" + vars["id"] + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	response.New(http.StatusOK, item).SendSuccess(w)
+}
+
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+
+	limit, limitErr := pagination.ParseLimit(r.URL.Query(), config.TagCloudPage)
+	if limitErr != nil {
+		response.New(limitErr.Code(), models.Message{Message: limitErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+	logger.Delivery().Info(ctx, logger.Fields{"request data": slug, "limit": limit})
+
+	forum, err := h.forumRepo.GetForumBySlug(ctx, slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 	if forum == nil {
 		message := models.Message{
-			Message: "Can't find forum with id #" + forumThreads.Slug + "\n",
+			Message: "Can't find forum with id #" + slug + "\n",
 		}
 		response.New(http.StatusNotFound, message).SendSuccess(w)
 		return
 	}
 
-	threads, err := h.forumRepo.GetThreads(ctx, forumThreads)
+	tags, err := h.forumRepo.GetTagCloud(ctx, forum.Slug, limit)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	response.New(http.StatusOK, threads).SendSuccess(w)
+	response.New(http.StatusOK, tags).SendSuccess(w)
 }
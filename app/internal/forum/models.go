@@ -12,11 +12,47 @@ type ForumHandler interface {
 	GetDetails(w http.ResponseWriter, r *http.Request)
 	GetUsers(w http.ResponseWriter, r *http.Request)
 	GetThreads(w http.ResponseWriter, r *http.Request)
+	UpdateDetails(w http.ResponseWriter, r *http.Request)
+	Archive(w http.ResponseWriter, r *http.Request)
+	DeleteForum(w http.ResponseWriter, r *http.Request)
+	AdminDeleteForum(w http.ResponseWriter, r *http.Request)
+	RenameForum(w http.ResponseWriter, r *http.Request)
+	GetForums(w http.ResponseWriter, r *http.Request)
+	GetRoles(w http.ResponseWriter, r *http.Request)
+	GrantRole(w http.ResponseWriter, r *http.Request)
+	RevokeRole(w http.ResponseWriter, r *http.Request)
+	Moderation(w http.ResponseWriter, r *http.Request)
+	Limits(w http.ResponseWriter, r *http.Request)
+	GetQueue(w http.ResponseWriter, r *http.Request)
+	ResolveQueueItem(w http.ResponseWriter, r *http.Request)
+	GetTags(w http.ResponseWriter, r *http.Request)
 }
 
 type ForumRepo interface {
 	CreateForum(ctx context.Context, forum *models.Forum) (int, error)
 	GetForumBySlug(ctx context.Context, title string) (*models.Forum, error)
-	GetUsers(ctx context.Context, forumUsers *models.ForumUsers) (*[]models.User, error)
-	GetThreads(ctx context.Context, forumThreads *models.ForumThreads) (*[]models.Thread, error)
+	GetForum(ctx context.Context, slug string) (*models.Forum, error)
+	GetUsers(ctx context.Context, forumUsers *models.ForumUsers, each func(user *models.User) error) error
+	GetThreads(ctx context.Context, forumThreads *models.ForumThreads, each func(thread *models.Thread) error) error
+	GetUserForums(ctx context.Context, name string) (*[]models.Forum, error)
+	UpdateForum(ctx context.Context, forum *models.Forum) error
+	SetArchived(ctx context.Context, slug string, archived bool) error
+	DeleteForum(ctx context.Context, slug string) error
+	RenameForum(ctx context.Context, oldSlug, newSlug string) error
+	GetForums(ctx context.Context) (*[]models.Forum, error)
+	GetChildren(ctx context.Context, slug string) (*[]models.Forum, error)
+	GetRole(ctx context.Context, slug, nickname string) (string, error)
+	GetRoles(ctx context.Context, slug string) (*[]models.ForumRole, error)
+	SetRole(ctx context.Context, role *models.ForumRole) error
+	DeleteRole(ctx context.Context, slug, nickname string) error
+	GetBannedAuthor(ctx context.Context, slug string, nicknames []string) (string, error)
+	SetModeration(ctx context.Context, slug string, moderation bool) error
+	SetLimits(ctx context.Context, slug string, limits *models.ForumLimits) error
+	GetPendingThreads(ctx context.Context, page *models.ForumQueuePage) (*[]models.Thread, error)
+	GetPendingPosts(ctx context.Context, page *models.ForumQueuePage) (*[]models.Post, error)
+	SetThreadStatus(ctx context.Context, slug string, id int, status string) (*models.Thread, error)
+	SetPostStatus(ctx context.Context, slug string, id int, status string) (*models.Post, error)
+	HideThread(ctx context.Context, slug string, id int) (bool, error)
+	HidePost(ctx context.Context, slug string, id int64) (bool, error)
+	GetTagCloud(ctx context.Context, slug string, limit int) (*[]models.TagCount, error)
 }
@@ -2,11 +2,12 @@ package repository
 
 import (
 	"context"
-	"strconv"
+	"strings"
 
 	forumModel "github.com/forums/app/internal/forum"
 	"github.com/forums/app/models"
 	"github.com/forums/utils/logger"
+	"github.com/forums/utils/prepared"
 	"github.com/jackc/pgx"
 )
 
@@ -24,13 +25,24 @@ func (r *repo) CreateForum(ctx context.Context, forum *models.Forum) (id int, er
 
 	query :=
 		`
-		INSERT INTO forums (title, user_create, slug) 
-		VALUES ($1, $2, $3) returning id
+		WITH dropped AS (
+			DELETE FROM forums_aliases WHERE old_slug = $3
+		)
+		INSERT INTO forums (title, user_create, slug, description, category, parent, moderation,
+		max_depth, max_thread_posts, depth_policy)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id
 	`
 	err = r.DB.QueryRow(query,
 		forum.Title,
 		forum.User,
-		forum.Slug).Scan(&id)
+		forum.Slug,
+		forum.Description,
+		forum.Category,
+		forum.Parent,
+		forum.Moderation,
+		forum.MaxDepth,
+		forum.MaxThreadPosts,
+		forum.DepthPolicy).Scan(&id)
 
 	if err != nil {
 		logger.Repo().AddFuncName("CreateForum").Error(ctx, err)
@@ -41,20 +53,69 @@ func (r *repo) CreateForum(ctx context.Context, forum *models.Forum) (id int, er
 	return id, nil
 }
 
+// GetForumBySlug для чтения: старый slug после переименования ведёт на текущий форум
 func (r *repo) GetForumBySlug(ctx context.Context, slug string) (*models.Forum, error) {
+	forum, err := r.GetForum(ctx, slug)
+	if err != nil || forum != nil {
+		return forum, err
+	}
+
+	canonical, err := r.getForumAlias(ctx, slug)
+	if err != nil || canonical == "" {
+		return nil, err
+	}
+
+	return r.GetForum(ctx, canonical)
+}
+
+var forumAlias = prepared.Register("forum_alias", `
+	SELECT fa.slug
+	FROM forums_aliases as fa
+	WHERE fa.old_slug = $1
+`)
+
+func (r *repo) getForumAlias(ctx context.Context, oldSlug string) (string, error) {
+	var slug string
+	err := r.DB.QueryRow(forumAlias, oldSlug).Scan(&slug)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("getForumAlias").Error(ctx, err)
+		return "", err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"forum alias": slug})
+	return slug, nil
+}
+
+var forumBySlug = prepared.Register("forum_by_slug", `
+	SELECT f.title, f.user_create, f.slug, f.threads, f.posts,
+	f.description, f.archived, f.moderation, f.category, f.parent,
+	f.threads_total, f.posts_total, f.max_depth, f.max_thread_posts, f.depth_policy
+	FROM forums as f
+	WHERE f.slug = $1
+`)
+
+// GetForum ищет только по текущему slug, для проверок занятости slug
+func (r *repo) GetForum(ctx context.Context, slug string) (*models.Forum, error) {
 	forum := new(models.Forum)
-	query :=
-		`
-		SELECT f.title, f.user_create, f.slug, f.threads, f.posts
-		FROM forums as f
-		WHERE f.slug = $1
-	`
-	err := r.DB.QueryRow(query, slug).Scan(
+	err := r.DB.QueryRow(forumBySlug, slug).Scan(
 		&forum.Title,
 		&forum.User,
 		&forum.Slug,
 		&forum.Threads,
 		&forum.Posts,
+		&forum.Description,
+		&forum.Archived,
+		&forum.Moderation,
+		&forum.Category,
+		&forum.Parent,
+		&forum.TotalThreads,
+		&forum.TotalPosts,
+		&forum.MaxDepth,
+		&forum.MaxThreadPosts,
+		&forum.DepthPolicy,
 	)
 
 	if err == pgx.ErrNoRows {
@@ -62,7 +123,7 @@ func (r *repo) GetForumBySlug(ctx context.Context, slug string) (*models.Forum,
 		return nil, nil
 	}
 	if err != nil {
-		logger.Repo().AddFuncName("GetForumBySlug").Error(ctx, err)
+		logger.Repo().AddFuncName("GetForum").Error(ctx, err)
 		return nil, err
 	}
 
@@ -70,48 +131,20 @@ func (r *repo) GetForumBySlug(ctx context.Context, slug string) (*models.Forum,
 	return forum, nil
 }
 
-func (r *repo) GetUsers(ctx context.Context, forumUsers *models.ForumUsers) (*[]models.User, error) {
-	var queryParams []interface{}
-	query :=
-		`
-		SELECT user_nickname, user_fullname, user_about, user_email
-		FROM forums_users
-		WHERE forum = $1
-	`
-	queryParams = append(queryParams, forumUsers.Slug)
-
-	if forumUsers.Desc {
-		if forumUsers.Since != "" {
-			query += " AND user_nickname < $2"
-			queryParams = append(queryParams, forumUsers.Since)
-		}
-
-		query += " ORDER BY user_nickname DESC"
-	} else {
-		if forumUsers.Since != "" {
-			query += " AND user_nickname > $2"
-			queryParams = append(queryParams, forumUsers.Since)
-		}
-
-		query += " ORDER BY user_nickname"
-	}
-
-	if forumUsers.Limit != "" {
-		query += " LIMIT " + forumUsers.Limit
-	}
+func (r *repo) GetUsers(ctx context.Context, forumUsers *models.ForumUsers, each func(user *models.User) error) error {
+	query, queryParams := usersQuery(forumUsers)
 
 	logger.Repo().AddFuncName("GetUsers").Debug(ctx, logger.Fields{"query": query})
 
 	usersDB, err := r.DB.Query(query, queryParams...)
 	if err != nil {
 		logger.Repo().AddFuncName("GetUsers").Error(ctx, err)
-		return nil, err
+		return err
 	}
+	defer usersDB.Close()
 
-	users := make([]models.User, 0)
 	for usersDB.Next() {
 		user := new(models.User)
-
 		err := usersDB.Scan(
 			&user.Nickname,
 			&user.Fullname,
@@ -121,59 +154,443 @@ func (r *repo) GetUsers(ctx context.Context, forumUsers *models.ForumUsers) (*[]
 
 		if err != nil {
 			logger.Repo().AddFuncName("GetUsers").Error(ctx, err)
+			return err
+		}
+
+		if err := each(user); err != nil {
+			return err
+		}
+	}
+
+	if err := usersDB.Err(); err != nil {
+		logger.Repo().AddFuncName("GetUsers").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) GetThreads(ctx context.Context, forumThreads *models.ForumThreads, each func(thread *models.Thread) error) error {
+	query, queryParams := threadsQuery(forumThreads)
+
+	logger.Repo().Debug(ctx, logger.Fields{"query": query})
+
+	threadsDB, err := r.DB.Query(query, queryParams...)
+	if err != nil {
+		logger.Repo().AddFuncName("GetThreads").Error(ctx, err)
+		return err
+	}
+	defer threadsDB.Close()
+
+	for threadsDB.Next() {
+		thread := new(models.Thread)
+		err := threadsDB.Scan(
+			&thread.Id,
+			&thread.Title,
+			&thread.Author,
+			&thread.Forum,
+			&thread.Message,
+			&thread.Slug,
+			&thread.Created,
+			&thread.Votes,
+			&thread.Closed,
+			&thread.Tags,
+			&thread.Posts,
+			&thread.LastPostAt,
+			&thread.Views,
+			&thread.Unread,
+		)
+
+		if err != nil {
+			logger.Repo().AddFuncName("GetThreads").Error(ctx, err)
+			return err
+		}
+
+		if err := each(thread); err != nil {
+			return err
+		}
+	}
+
+	if err := threadsDB.Err(); err != nil {
+		logger.Repo().AddFuncName("GetThreads").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) queryForums(ctx context.Context, funcName string, query string, args ...interface{}) (*[]models.Forum, error) {
+	forumsDB, err := r.DB.Query(query, args...)
+	if err != nil {
+		logger.Repo().AddFuncName(funcName).Error(ctx, err)
+		return nil, err
+	}
+	defer forumsDB.Close()
+
+	forums := make([]models.Forum, 0)
+	for forumsDB.Next() {
+		forum := new(models.Forum)
+		err := forumsDB.Scan(
+			&forum.Title,
+			&forum.User,
+			&forum.Slug,
+			&forum.Threads,
+			&forum.Posts,
+			&forum.Description,
+			&forum.Archived,
+			&forum.Moderation,
+			&forum.Category,
+			&forum.Parent,
+			&forum.TotalThreads,
+			&forum.TotalPosts,
+			&forum.MaxDepth,
+			&forum.MaxThreadPosts,
+			&forum.DepthPolicy,
+		)
+
+		if err != nil {
+			logger.Repo().AddFuncName(funcName).Error(ctx, err)
 			return nil, err
 		}
 
-		users = append(users, *user)
+		forums = append(forums, *forum)
 	}
 
-	logger.Repo().Info(ctx, logger.Fields{"users": users})
-	return &users, nil
+	return &forums, nil
+}
+
+func (r *repo) GetUserForums(ctx context.Context, name string) (*[]models.Forum, error) {
+	query :=
+		`
+		SELECT f.title, f.user_create, f.slug, f.threads, f.posts,
+		f.description, f.archived, f.moderation, f.category, f.parent,
+		f.threads_total, f.posts_total, f.max_depth, f.max_thread_posts, f.depth_policy
+		FROM forums as f
+		WHERE f.user_create = $1
+		ORDER BY f.id
+	`
+
+	return r.queryForums(ctx, "GetUserForums", query, name)
 }
 
-func (r *repo) GetThreads(ctx context.Context, forumThreads *models.ForumThreads) (*[]models.Thread, error) {
-	// TODO: подумать как здесь можно сделать покрасивее
-	var queryParams []interface{}
+func (r *repo) GetForums(ctx context.Context) (*[]models.Forum, error) {
 	query :=
 		`
-		SELECT th.id, th.title, th.user_create, th.forum, 
-		th.message, th.slug, th.created, th.votes
-		FROM threads as th
-		WHERE th.forum = $1
+		SELECT f.title, f.user_create, f.slug, f.threads, f.posts,
+		f.description, f.archived, f.moderation, f.category, f.parent,
+		f.threads_total, f.posts_total, f.max_depth, f.max_thread_posts, f.depth_policy
+		FROM forums as f
+		ORDER BY f.category, f.title, f.id
 	`
-	queryParams = append(queryParams, forumThreads.Slug)
 
-	if forumThreads.Desc {
-		if forumThreads.Since != "" {
-			query += " AND th.created <= $2"
-			queryParams = append(queryParams, forumThreads.Since)
+	return r.queryForums(ctx, "GetForums", query)
+}
+
+func (r *repo) GetChildren(ctx context.Context, slug string) (*[]models.Forum, error) {
+	query :=
+		`
+		SELECT f.title, f.user_create, f.slug, f.threads, f.posts,
+		f.description, f.archived, f.moderation, f.category, f.parent,
+		f.threads_total, f.posts_total, f.max_depth, f.max_thread_posts, f.depth_policy
+		FROM forums as f
+		WHERE f.parent = $1
+		ORDER BY f.title, f.id
+	`
+
+	return r.queryForums(ctx, "GetChildren", query, slug)
+}
+
+func (r *repo) UpdateForum(ctx context.Context, forum *models.Forum) error {
+	query :=
+		`
+		UPDATE forums SET title = $1, description = $2
+		WHERE slug = $3
+	`
+
+	_, err := r.DB.Exec(query, forum.Title, forum.Description, forum.Slug)
+	if err != nil {
+		logger.Repo().AddFuncName("UpdateForum").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) SetArchived(ctx context.Context, slug string, archived bool) error {
+	query :=
+		`
+		UPDATE forums SET archived = $1
+		WHERE slug = $2
+	`
+
+	_, err := r.DB.Exec(query, archived, slug)
+	if err != nil {
+		logger.Repo().AddFuncName("SetArchived").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) DeleteForum(ctx context.Context, slug string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		logger.Repo().AddFuncName("DeleteForum").Error(ctx, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// удаляем явно, а не через ON DELETE CASCADE, чтобы голоса удалялись одним запросом;
+	// подфорумы станут корневыми, поэтому у предков вычитаем всё поддерево целиком
+	queries := []string{
+		`UPDATE forums AS a
+		SET threads_total = a.threads_total - f.threads_total, posts_total = a.posts_total - f.posts_total
+		FROM forums AS f
+		WHERE f.slug = $1 AND a.id = ANY(f.path) AND a.id <> f.id`,
+		`DELETE FROM votes WHERE thread IN (SELECT id FROM threads WHERE forum = $1)`,
+		`DELETE FROM posts WHERE forum = $1`,
+		`DELETE FROM threads WHERE forum = $1`,
+		`DELETE FROM forums_users WHERE forum = $1`,
+		`DELETE FROM forums WHERE slug = $1`,
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(query, slug)
+		if err != nil {
+			logger.Repo().AddFuncName("DeleteForum").Error(ctx, err)
+			return err
 		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Repo().AddFuncName("DeleteForum").Error(ctx, err)
+		return err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"deleted forum": slug})
+	return nil
+}
+
+func (r *repo) RenameForum(ctx context.Context, oldSlug, newSlug string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		logger.Repo().AddFuncName("RenameForum").Error(ctx, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// threads, posts, forums_users и forums_aliases обновятся через ON UPDATE CASCADE
+	query :=
+		`
+		UPDATE forums SET slug = $1
+		WHERE slug = $2
+	`
+	_, err = tx.Exec(query, newSlug, oldSlug)
+	if err != nil {
+		logger.Repo().AddFuncName("RenameForum").Error(ctx, err)
+		return err
+	}
+
+	query =
+		`
+		DELETE FROM forums_aliases
+		WHERE old_slug = $1
+	`
+	_, err = tx.Exec(query, newSlug)
+	if err != nil {
+		logger.Repo().AddFuncName("RenameForum").Error(ctx, err)
+		return err
+	}
 
-		query += " ORDER BY th.created DESC"
-	} else {
-		if forumThreads.Since != "" {
-			query += " AND th.created >= $2"
-			queryParams = append(queryParams, forumThreads.Since)
+	if !strings.EqualFold(oldSlug, newSlug) {
+		query =
+			`
+			INSERT INTO forums_aliases (old_slug, slug)
+			VALUES ($1, $2)
+			ON CONFLICT (old_slug) DO UPDATE SET slug = EXCLUDED.slug
+		`
+		_, err = tx.Exec(query, oldSlug, newSlug)
+		if err != nil {
+			logger.Repo().AddFuncName("RenameForum").Error(ctx, err)
+			return err
 		}
-		query += " ORDER BY th.created"
 	}
 
-	if forumThreads.Limit != 0 {
-		query += " LIMIT " + strconv.Itoa(forumThreads.Limit)
+	err = tx.Commit()
+	if err != nil {
+		logger.Repo().AddFuncName("RenameForum").Error(ctx, err)
+		return err
 	}
 
-	logger.Repo().Debug(ctx, logger.Fields{"query": query})
+	logger.Repo().Debug(ctx, logger.Fields{"old slug": oldSlug, "new slug": newSlug})
+	return nil
+}
+
+func (r *repo) GetRole(ctx context.Context, slug, nickname string) (string, error) {
+	query :=
+		`
+		SELECT CASE WHEN f.user_create = $2 THEN 'owner' ELSE COALESCE(fr.role, '') END
+		FROM forums as f
+		LEFT JOIN forums_roles as fr ON fr.forum = f.slug AND fr.user_nickname = $2
+		WHERE f.slug = $1
+	`
+
+	var role string
+	err := r.DB.QueryRow(query, slug, nickname).Scan(&role)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("GetRole").Error(ctx, err)
+		return "", err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"role": role})
+	return role, nil
+}
+
+func (r *repo) GetRoles(ctx context.Context, slug string) (*[]models.ForumRole, error) {
+	query :=
+		`
+		SELECT f.slug, f.user_create, 'owner'
+		FROM forums as f
+		WHERE f.slug = $1
+		UNION ALL
+		SELECT fr.forum, fr.user_nickname, fr.role
+		FROM forums_roles as fr
+		JOIN forums as f ON f.slug = fr.forum
+		WHERE fr.forum = $1 AND fr.user_nickname <> f.user_create
+	`
+
+	rolesDB, err := r.DB.Query(query, slug)
+	if err != nil {
+		logger.Repo().AddFuncName("GetRoles").Error(ctx, err)
+		return nil, err
+	}
+	defer rolesDB.Close()
+
+	roles := make([]models.ForumRole, 0)
+	for rolesDB.Next() {
+		role := new(models.ForumRole)
+		err := rolesDB.Scan(
+			&role.Forum,
+			&role.Nickname,
+			&role.Role,
+		)
+
+		if err != nil {
+			logger.Repo().AddFuncName("GetRoles").Error(ctx, err)
+			return nil, err
+		}
+
+		roles = append(roles, *role)
+	}
+
+	return &roles, nil
+}
+
+func (r *repo) SetRole(ctx context.Context, role *models.ForumRole) error {
+	query :=
+		`
+		INSERT INTO forums_roles (forum, user_nickname, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (forum, user_nickname) DO UPDATE SET role = EXCLUDED.role
+	`
+
+	_, err := r.DB.Exec(query, role.Forum, role.Nickname, role.Role)
+	if err != nil {
+		logger.Repo().AddFuncName("SetRole").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) DeleteRole(ctx context.Context, slug, nickname string) error {
+	query :=
+		`
+		DELETE FROM forums_roles
+		WHERE forum = $1 AND user_nickname = $2
+	`
+
+	_, err := r.DB.Exec(query, slug, nickname)
+	if err != nil {
+		logger.Repo().AddFuncName("DeleteRole").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) GetBannedAuthor(ctx context.Context, slug string, nicknames []string) (string, error) {
+	query :=
+		`
+		SELECT fr.user_nickname
+		FROM forums_roles as fr
+		WHERE fr.forum = $1 AND fr.role = 'banned' AND fr.user_nickname = ANY($2::citext[])
+		LIMIT 1
+	`
+
+	var nickname string
+	err := r.DB.QueryRow(query, slug, nicknames).Scan(&nickname)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("GetBannedAuthor").Error(ctx, err)
+		return "", err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"banned": nickname})
+	return nickname, nil
+}
+
+func (r *repo) SetModeration(ctx context.Context, slug string, moderation bool) error {
+	query :=
+		`
+		UPDATE forums SET moderation = $1
+		WHERE slug = $2
+	`
+
+	_, err := r.DB.Exec(query, moderation, slug)
+	if err != nil {
+		logger.Repo().AddFuncName("SetModeration").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) SetLimits(ctx context.Context, slug string, limits *models.ForumLimits) error {
+	query :=
+		`
+		UPDATE forums SET max_depth = $1, max_thread_posts = $2, depth_policy = $3
+		WHERE slug = $4
+	`
+
+	_, err := r.DB.Exec(query, limits.MaxDepth, limits.MaxThreadPosts, limits.DepthPolicy, slug)
+	if err != nil {
+		logger.Repo().AddFuncName("SetLimits").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) GetPendingThreads(ctx context.Context, page *models.ForumQueuePage) (*[]models.Thread, error) {
+	query, queryParams := pendingThreadsQuery(page)
 
 	threadsDB, err := r.DB.Query(query, queryParams...)
 	if err != nil {
-		logger.Repo().AddFuncName("GetThreads").Error(ctx, err)
+		logger.Repo().AddFuncName("GetPendingThreads").Error(ctx, err)
 		return nil, err
 	}
+	defer threadsDB.Close()
 
 	threads := make([]models.Thread, 0)
 	for threadsDB.Next() {
-		thread := new(models.Thread)
-
+		thread := &models.Thread{Pending: true}
 		err := threadsDB.Scan(
 			&thread.Id,
 			&thread.Title,
@@ -183,17 +600,183 @@ func (r *repo) GetThreads(ctx context.Context, forumThreads *models.ForumThreads
 			&thread.Slug,
 			&thread.Created,
 			&thread.Votes,
+			&thread.Closed,
+			&thread.Tags,
+			&thread.Posts,
+			&thread.LastPostAt,
+			&thread.Views,
 		)
 
 		if err != nil {
-			logger.Repo().AddFuncName("GetThreads").Error(ctx, err)
+			logger.Repo().AddFuncName("GetPendingThreads").Error(ctx, err)
 			return nil, err
 		}
 
 		threads = append(threads, *thread)
 	}
 
-	logger.Repo().AddFuncName("GetThreads").Info(ctx, logger.Fields{"threads": threads})
-
 	return &threads, nil
 }
+
+func (r *repo) GetPendingPosts(ctx context.Context, page *models.ForumQueuePage) (*[]models.Post, error) {
+	query, queryParams := pendingPostsQuery(page)
+
+	postsDB, err := r.DB.Query(query, queryParams...)
+	if err != nil {
+		logger.Repo().AddFuncName("GetPendingPosts").Error(ctx, err)
+		return nil, err
+	}
+	defer postsDB.Close()
+
+	posts := make([]models.Post, 0)
+	for postsDB.Next() {
+		post := &models.Post{Pending: true}
+		err := postsDB.Scan(
+			&post.Id,
+			&post.Parent,
+			&post.Author,
+			&post.Message,
+			&post.IsEdited,
+			&post.Forum,
+			&post.Thread,
+			&post.Created,
+			&post.Depth,
+		)
+
+		if err != nil {
+			logger.Repo().AddFuncName("GetPendingPosts").Error(ctx, err)
+			return nil, err
+		}
+
+		posts = append(posts, *post)
+	}
+
+	return &posts, nil
+}
+
+// SetThreadStatus решает судьбу ветки из очереди, nil - такой ветки в очереди нет
+func (r *repo) SetThreadStatus(ctx context.Context, slug string, id int, status string) (*models.Thread, error) {
+	query :=
+		`
+		UPDATE threads SET status = $1
+		WHERE id = $2 AND forum = $3 AND status = 'pending'
+		RETURNING id, title, user_create, forum, message, slug, created, votes, closed, tags,
+		posts, last_post_at, views
+	`
+
+	thread := new(models.Thread)
+	err := r.DB.QueryRow(query, status, id, slug).Scan(
+		&thread.Id,
+		&thread.Title,
+		&thread.Author,
+		&thread.Forum,
+		&thread.Message,
+		&thread.Slug,
+		&thread.Created,
+		&thread.Votes,
+		&thread.Closed,
+		&thread.Tags,
+		&thread.Posts,
+		&thread.LastPostAt,
+		&thread.Views,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("SetThreadStatus").Error(ctx, err)
+		return nil, err
+	}
+
+	return thread, nil
+}
+
+// SetPostStatus решает судьбу поста из очереди, nil - такого поста в очереди нет
+func (r *repo) SetPostStatus(ctx context.Context, slug string, id int, status string) (*models.Post, error) {
+	query :=
+		`
+		UPDATE posts SET status = $1
+		WHERE id = $2 AND forum = $3 AND status = 'pending'
+		RETURNING id, parent, user_create, message, is_edited, forum, thread, created,
+		array_length(tree, 1) - 1
+	`
+
+	post := new(models.Post)
+	err := r.DB.QueryRow(query, status, id, slug).Scan(
+		&post.Id,
+		&post.Parent,
+		&post.Author,
+		&post.Message,
+		&post.IsEdited,
+		&post.Forum,
+		&post.Thread,
+		&post.Created,
+		&post.Depth,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("SetPostStatus").Error(ctx, err)
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (r *repo) HideThread(ctx context.Context, slug string, id int) (bool, error) {
+	query :=
+		`
+		UPDATE threads SET status = 'rejected'
+		WHERE id = $1 AND forum = $2 AND status = 'approved'
+	`
+
+	result, err := r.DB.Exec(query, id, slug)
+	if err != nil {
+		logger.Repo().AddFuncName("HideThread").Error(ctx, err)
+		return false, err
+	}
+
+	return result.RowsAffected() != 0, nil
+}
+
+func (r *repo) HidePost(ctx context.Context, slug string, id int64) (bool, error) {
+	query :=
+		`
+		UPDATE posts SET status = 'rejected'
+		WHERE id = $1 AND forum = $2 AND status = 'approved'
+	`
+
+	result, err := r.DB.Exec(query, id, slug)
+	if err != nil {
+		logger.Repo().AddFuncName("HidePost").Error(ctx, err)
+		return false, err
+	}
+
+	return result.RowsAffected() != 0, nil
+}
+
+func (r *repo) GetTagCloud(ctx context.Context, slug string, limit int) (*[]models.TagCount, error) {
+	query, queryParams := tagCloudQuery(slug, limit)
+
+	tagsDB, err := r.DB.Query(query, queryParams...)
+	if err != nil {
+		logger.Repo().AddFuncName("GetTagCloud").Error(ctx, err)
+		return nil, err
+	}
+	defer tagsDB.Close()
+
+	tags := make([]models.TagCount, 0)
+	for tagsDB.Next() {
+		tag := new(models.TagCount)
+		err := tagsDB.Scan(&tag.Tag, &tag.Threads)
+		if err != nil {
+			logger.Repo().AddFuncName("GetTagCloud").Error(ctx, err)
+			return nil, err
+		}
+
+		tags = append(tags, *tag)
+	}
+
+	return &tags, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/query"
+)
+
+// Списочные запросы собираются отдельно от выполнения, чтобы их текст проверялся golden-тестами
+
+// ключи сортировки веток кроме created, %s - алиас таблицы threads
+var threadSortKeys = map[string]string{
+	models.SortVotes:    "%[1]s.votes",
+	models.SortActivity: "%[1]s.last_post_at",
+	models.SortReplies:  "%[1]s.posts",
+	models.SortHot:      "thread_hot(%[1]s.votes, %[1]s.last_post_at)",
+}
+
+// непрочитанные читателем посты ветки, ? - никнейм читателя
+const unreadPosts = `(
+	SELECT count(*) FROM posts as p
+	WHERE p.thread = th.id AND p.status = 'approved' AND p.id > COALESCE(
+		(SELECT tr.last_read FROM threads_reads as tr WHERE tr.thread = th.id AND tr.user_nickname = ?), 0)
+)`
+
+func usersQuery(forumUsers *models.ForumUsers) (string, []interface{}) {
+	return query.New("SELECT user_nickname, user_fullname, user_about, user_email FROM forums_users").
+		Where("forum = ?", forumUsers.Slug).
+		Where("NOT EXISTS (SELECT 1 FROM users as u WHERE u.nickname = user_nickname AND u.deactivated)").
+		Desc(forumUsers.Desc).
+		Since("user_nickname", "?", forumUsers.Since).
+		OrderBy("user_nickname").
+		Limit(forumUsers.Limit).
+		Build()
+}
+
+func threadsQuery(forumThreads *models.ForumThreads) (string, []interface{}) {
+	var unreadArgs []interface{}
+	unread := "NULL::integer"
+	if forumThreads.Viewer != "" {
+		unread = unreadPosts
+		unreadArgs = append(unreadArgs, forumThreads.Viewer)
+	}
+
+	q := query.New(`
+		SELECT th.id, th.title, th.user_create, th.forum,
+		th.message, th.slug, th.created, th.votes, th.closed, th.tags,
+		th.posts, th.last_post_at, th.views, `+unread+`
+		FROM threads as th
+	`, unreadArgs...).
+		Where("th.forum = ?", forumThreads.Slug).
+		Where("th.status = 'approved'").
+		Desc(forumThreads.Desc)
+
+	if forumThreads.Tag != "" {
+		q.Where("th.tags @> ARRAY[?]::text[]", forumThreads.Tag)
+	}
+
+	if key, ok := threadSortKeys[forumThreads.Sort]; ok {
+		// since - id последней полученной ветки, страницы идут по (ключ, id) без пропусков и повторов
+		q.Since("("+fmt.Sprintf(key, "th")+", th.id)",
+			"(SELECT "+fmt.Sprintf(key, "cur")+", cur.id FROM threads as cur WHERE cur.id = ?)", forumThreads.Since).
+			OrderBy(fmt.Sprintf(key, "th"), "th.id")
+	} else {
+		q.SinceInclusive("th.created", "?", forumThreads.Since).
+			OrderBy("th.created")
+	}
+
+	return q.Limit(forumThreads.Limit).Build()
+}
+
+func tagCloudQuery(slug string, limit int) (string, []interface{}) {
+	return query.New("SELECT tag, count(*) FROM threads as th, unnest(th.tags) as tag").
+		Where("th.forum = ?", slug).
+		Where("th.status = 'approved'").
+		GroupBy("tag").
+		OrderAsc("count(*) DESC", "tag").
+		Limit(limit).
+		Build()
+}
+
+func pendingThreadsQuery(page *models.ForumQueuePage) (string, []interface{}) {
+	return query.New(`
+		SELECT th.id, th.title, th.user_create, th.forum,
+		th.message, th.slug, th.created, th.votes, th.closed, th.tags,
+		th.posts, th.last_post_at, th.views
+		FROM threads as th
+	`).
+		Where("th.forum = ?", page.Slug).
+		Where("th.status = 'pending'").
+		Desc(page.Desc).
+		Since("th.id", "?", page.Since).
+		OrderBy("th.id").
+		Limit(page.Limit).
+		Build()
+}
+
+func pendingPostsQuery(page *models.ForumQueuePage) (string, []interface{}) {
+	return query.New(`
+		SELECT p.id, p.parent, p.user_create, p.message,
+		p.is_edited, p.forum, p.thread, p.created, array_length(p.tree, 1) - 1
+		FROM posts as p
+	`).
+		Where("p.forum = ?", page.Slug).
+		Where("p.status = 'pending'").
+		Desc(page.Desc).
+		Since("p.id", "?", page.Since).
+		OrderBy("p.id").
+		Limit(page.Limit).
+		Build()
+}
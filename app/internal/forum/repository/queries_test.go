@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/query/querytest"
+)
+
+func TestUsersQuery(t *testing.T) {
+	axes := []querytest.Axis{querytest.Desc(), querytest.Since("neo"), querytest.Limit()}
+
+	querytest.Golden(t, "users", querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+		return usersQuery(&models.ForumUsers{
+			Slug: "golang", Desc: p.Bool("desc"), Since: p.String("since"), Limit: p.Int("limit"),
+		})
+	}))
+}
+
+func TestThreadsQuery(t *testing.T) {
+	sorts := []string{"", models.SortVotes, models.SortActivity, models.SortReplies, models.SortHot}
+
+	var cases []querytest.Case
+	for _, sort := range sorts {
+		// без сортировки since - дата, иначе значение ключа сортировки
+		since := "2020-01-01T00:00:00Z"
+		if sort != "" {
+			since = "42"
+		}
+
+		axes := []querytest.Axis{
+			querytest.Strings("sort", sort),
+			querytest.Strings("viewer", "", "neo"),
+			querytest.Strings("tag", "", "go"),
+			querytest.Desc(), querytest.Since(since), querytest.Limit(),
+		}
+		cases = append(cases, querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+			return threadsQuery(&models.ForumThreads{
+				Slug: "golang", Sort: p.String("sort"), Viewer: p.String("viewer"), Tag: p.String("tag"),
+				Desc: p.Bool("desc"), Since: p.String("since"), Limit: p.Int("limit"),
+			})
+		})...)
+	}
+
+	querytest.Golden(t, "threads", cases)
+}
+
+func TestTagCloudQuery(t *testing.T) {
+	querytest.Golden(t, "tag_cloud", querytest.Matrix([]querytest.Axis{querytest.Limit()}, func(p querytest.Params) (string, []interface{}) {
+		return tagCloudQuery("golang", p.Int("limit"))
+	}))
+}
+
+func TestPendingQueries(t *testing.T) {
+	axes := []querytest.Axis{querytest.Desc(), querytest.Since("42"), querytest.Limit()}
+	page := func(p querytest.Params) *models.ForumQueuePage {
+		return &models.ForumQueuePage{
+			Slug: "golang", Desc: p.Bool("desc"), Since: p.String("since"), Limit: p.Int("limit"),
+		}
+	}
+
+	querytest.Golden(t, "pending_threads", querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+		return pendingThreadsQuery(page(p))
+	}))
+	querytest.Golden(t, "pending_posts", querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+		return pendingPostsQuery(page(p))
+	}))
+}
@@ -4,9 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/forums/app/config"
+	filterModel "github.com/forums/app/internal/filter"
 	forumModel "github.com/forums/app/internal/forum"
 	postModel "github.com/forums/app/internal/post"
 	threadModel "github.com/forums/app/internal/thread"
@@ -14,6 +15,7 @@ import (
 	"github.com/forums/app/models"
 	"github.com/forums/utils/errors"
 	"github.com/forums/utils/logger"
+	"github.com/forums/utils/pagination"
 	"github.com/forums/utils/response"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgerrcode"
@@ -21,19 +23,22 @@ import (
 )
 
 type Handler struct {
-	postRepo   postModel.PostRepo
-	userRepo   userModel.UserRepo
-	threadRepo threadModel.ThreadRepo
-	forumRepo  forumModel.ForumRepo
+	postRepo      postModel.PostRepo
+	userRepo      userModel.UserRepo
+	threadRepo    threadModel.ThreadRepo
+	forumRepo     forumModel.ForumRepo
+	filterUsecase filterModel.FilterUsecase
 }
 
 func NewPostHandler(postRepo postModel.PostRepo, userRepo userModel.UserRepo,
-	threadRepo threadModel.ThreadRepo, forumRepo forumModel.ForumRepo) postModel.PostHandler {
+	threadRepo threadModel.ThreadRepo, forumRepo forumModel.ForumRepo,
+	filterUsecase filterModel.FilterUsecase) postModel.PostHandler {
 	return &Handler{
-		postRepo:   postRepo,
-		userRepo:   userRepo,
-		threadRepo: threadRepo,
-		forumRepo:  forumRepo,
+		postRepo:      postRepo,
+		userRepo:      userRepo,
+		threadRepo:    threadRepo,
+		forumRepo:     forumRepo,
+		filterUsecase: filterUsecase,
 	}
 }
 
@@ -70,16 +75,84 @@ func (h *Handler) CreatePosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	forum, err := h.forumRepo.GetForumBySlug(ctx, thread.Forum)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forum != nil && forum.Archived {
+		message := models.Message{
+			Message: "Forum #" + forum.Slug + " is archived\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	if thread.Pending {
+		message := models.Message{
+			Message: "Thread #" + strconv.Itoa(thread.Id) + " is awaiting moderation\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	if thread.Closed {
+		message := models.Message{
+			Message: "Thread #" + strconv.Itoa(thread.Id) + " is closed\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
 	if len(posts) == 0 {
 		response.New(http.StatusCreated, posts).SendSuccess(w)
 		return
 	}
 
+	authors := make([]string, 0, len(posts))
+	for i := range posts {
+		authors = append(authors, posts[i].Author)
+	}
+
+	banned, err := h.forumRepo.GetBannedAuthor(ctx, thread.Forum, authors)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if banned != "" {
+		message := models.Message{
+			Message: "User #" + banned + " is banned in forum #" + thread.Forum + "\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	contents := make([]*models.FilterContent, 0, len(posts))
+	for i := range posts {
+		contents = append(contents, &models.FilterContent{
+			Forum:   thread.Forum,
+			Author:  posts[i].Author,
+			Message: posts[i].Message,
+		})
+	}
+
+	rejection, err := h.filterUsecase.Check(ctx, contents)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if rejection != nil {
+		response.New(http.StatusBadRequest, models.Message{Message: rejection.Message}).SendSuccess(w)
+		return
+	}
+
 	logger.Usecase().Debug(ctx, logger.Fields{"forum slug": thread.Forum})
 	for i := range posts {
 		posts[i].Thread = thread.Id
 		posts[i].Forum = thread.Forum
 		posts[i].Created = timeNow
+		posts[i].Message = contents[i].Message
+		posts[i].Pending = (forum != nil && forum.Moderation) || contents[i].Queued
 	}
 
 	postsDB, err := h.postRepo.CreatePosts(ctx, &posts)
@@ -104,6 +177,27 @@ func (h *Handler) CreatePosts(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 
+			case "12346": // пользователь деактивирован
+				message := models.Message{
+					Message: "User is deactivated\n",
+				}
+				response.New(http.StatusForbidden, message).SendSuccess(w)
+				return
+
+			case "12347": // превышена глубина ответов форума
+				message := models.Message{
+					Message: "Reply is too deep: " + pqErr.Message + "\n",
+				}
+				response.New(http.StatusBadRequest, message).SendSuccess(w)
+				return
+
+			case "12348": // в ветке больше нет места
+				message := models.Message{
+					Message: "Thread #" + strconv.Itoa(thread.Id) + " is full: " + pqErr.Message + "\n",
+				}
+				response.New(http.StatusConflict, message).SendSuccess(w)
+				return
+
 			default:
 				logger.Usecase().AddFuncName("CreatePosts").Error(ctx, err)
 				w.WriteHeader(http.StatusInternalServerError)
@@ -132,15 +226,16 @@ func (h *Handler) GetDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	related.Id = id
-	logger.Delivery().Info(ctx, logger.Fields{"request data": *related})
+	viewer := r.URL.Query().Get("user")
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *related, "user": viewer})
 
-	// TODO: подумать надо оптимизацией, получениявсех данных одним запросом
-	post, err := h.postRepo.GetPost(ctx, related.Id)
+	// пост на модерации видит только модератор форума, для остальных его нет
+	infoPost, err := h.postRepo.GetPostDetails(ctx, int64(related.Id), models.ParseRelated(related.Related), viewer)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if post == nil {
+	if infoPost == nil {
 		message := models.Message{
 			Message: "Can't find post with id #" + strconv.Itoa(related.Id) + "\n",
 		}
@@ -148,41 +243,57 @@ func (h *Handler) GetDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	infoPost := models.InfoPost{
-		Post:   post,
-		User:   nil,
-		Forum:  nil,
-		Thread: nil,
+	response.New(http.StatusOK, infoPost).SendSuccess(w)
+}
+
+// GetManyDetails - GetDetails для пачки постов, ненайденные id в ответ не попадают
+func (h *Handler) GetManyDetails(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	request := new(models.PostsDetailsRequest)
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
 	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *request})
 
-	if strings.Contains(related.Related, "user") {
-		user, err := h.userRepo.GetUserByName(ctx, post.Author)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+	if len(request.Ids) > config.MaxPostsDetails {
+		message := models.Message{
+			Message: "Can't request more than " + strconv.Itoa(config.MaxPostsDetails) + " posts\n",
 		}
-		infoPost.User = user
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
 	}
 
-	if strings.Contains(related.Related, "forum") {
-		forum, err := h.forumRepo.GetForumBySlug(ctx, post.Forum)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+	var related models.PostRelated
+	for _, name := range request.Related {
+		switch name {
+		case "user":
+			related.User = true
+		case "forum":
+			related.Forum = true
+		case "thread":
+			related.Thread = true
+		default:
+			message := models.Message{
+				Message: "Invalid related " + name + "\n",
+			}
+			response.New(http.StatusBadRequest, message).SendSuccess(w)
 			return
 		}
-		infoPost.Forum = forum
 	}
 
-	if strings.Contains(related.Related, "thread") {
-		thread, err := h.threadRepo.GetThreadBySlugOrId(ctx, strconv.Itoa(post.Thread))
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		infoPost.Thread = thread
+	infos, err := h.postRepo.GetPostsDetails(ctx, request.Ids, related, r.URL.Query().Get("user"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	response.New(http.StatusOK, infoPost).SendSuccess(w)
+	response.New(http.StatusOK, infos).SendSuccess(w)
 }
 
 func (h *Handler) UpdateDetails(w http.ResponseWriter, r *http.Request) {
@@ -221,11 +332,34 @@ func (h *Handler) UpdateDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	actor := r.URL.Query().Get("user")
+	if !forumModel.CheckEditor(w, r, h.forumRepo, post.Forum, post.Author, actor, "post #"+strconv.FormatInt(post.Id, 10)) {
+		return
+	}
+
 	if post.Message == message.Message || message.Message == "" {
 		response.New(http.StatusOK, post).SendSuccess(w)
 		return
 	}
 
+	content := &models.FilterContent{
+		Id:      post.Id,
+		Forum:   post.Forum,
+		Author:  post.Author,
+		Message: message.Message,
+		Edit:    true,
+	}
+	rejection, err := h.filterUsecase.Check(ctx, []*models.FilterContent{content})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if rejection != nil {
+		response.New(http.StatusBadRequest, models.Message{Message: rejection.Message}).SendSuccess(w)
+		return
+	}
+
+	message.Message = content.Message
 	post.Message = message.Message
 	post.IsEdited = true
 
@@ -237,3 +371,235 @@ func (h *Handler) UpdateDetails(w http.ResponseWriter, r *http.Request) {
 
 	response.New(http.StatusOK, post).SendSuccess(w)
 }
+
+func (h *Handler) Split(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	actor := r.URL.Query().Get("user")
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+
+	split := new(models.SplitPostRequest)
+	err = json.NewDecoder(r.Body).Decode(&split)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *split, "id": id, "user": actor})
+
+	if split.Title == "" {
+		message := models.Message{
+			Message: "Thread title is required\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
+	post, err := h.postRepo.GetPost(ctx, id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if post == nil {
+		message := models.Message{
+			Message: "Can't find post with id #" + vars["id"] + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+	if post.Pending {
+		message := models.Message{
+			Message: "Post #" + vars["id"] + " is awaiting moderation\n",
+		}
+		response.New(http.StatusConflict, message).SendSuccess(w)
+		return
+	}
+
+	if split.Forum == "" {
+		split.Forum = post.Forum
+	}
+	forum, err := h.forumRepo.GetForumBySlug(ctx, split.Forum)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + split.Forum + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+	if forum.Archived {
+		message := models.Message{
+			Message: "Forum #" + forum.Slug + " is archived\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	if !forumModel.CheckModerator(w, r, h.forumRepo, post.Forum, actor) || !forumModel.CheckModerator(w, r, h.forumRepo, forum.Slug, actor) {
+		return
+	}
+
+	if split.Slug != "" {
+		oldThread, err := h.threadRepo.GetThread(ctx, split.Slug)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if oldThread != nil {
+			response.New(http.StatusConflict, oldThread).SendSuccess(w)
+			return
+		}
+	}
+
+	if split.Message == "" {
+		split.Message = "Split from thread #" + strconv.Itoa(post.Thread)
+	}
+
+	timeNow := time.Now()
+	thread := &models.Thread{
+		Title:   split.Title,
+		Author:  actor,
+		Message: split.Message,
+		Forum:   forum.Slug,
+		Slug:    split.Slug,
+		Created: &timeNow,
+	}
+
+	placeholder, err := h.threadRepo.SplitPosts(ctx, post.Id, thread)
+	if err != nil {
+		if pqErr, ok := err.(pgx.PgError); ok {
+			switch pqErr.Code {
+			case pgerrcode.ForeignKeyViolation:
+				message := models.Message{
+					Message: "Can't find user with id #" + actor + "\n",
+				}
+				response.New(http.StatusNotFound, message).SendSuccess(w)
+				return
+
+			case "12346": // пользователь деактивирован
+				message := models.Message{
+					Message: "User is deactivated\n",
+				}
+				response.New(http.StatusForbidden, message).SendSuccess(w)
+				return
+
+			case "12348": // заглушка не помещается в исходную ветку
+				message := models.Message{
+					Message: "Thread #" + strconv.Itoa(post.Thread) + " is full: " + pqErr.Message + "\n",
+				}
+				response.New(http.StatusConflict, message).SendSuccess(w)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	thread, err = h.threadRepo.GetThreadBySlugOrId(ctx, strconv.Itoa(thread.Id))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusCreated, models.InfoSplit{Thread: thread, Placeholder: placeholder}).SendSuccess(w)
+}
+
+// visiblePost отвечает 404, если пост не найден или ещё на модерации
+func (h *Handler) visiblePost(w http.ResponseWriter, r *http.Request) (*models.Post, bool) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return nil, false
+	}
+
+	post, err := h.postRepo.GetPost(ctx, id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if post == nil || post.Pending {
+		message := models.Message{
+			Message: "Can't find post with id #" + strconv.Itoa(id) + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return nil, false
+	}
+
+	return post, true
+}
+
+func (h *Handler) GetReplies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	post, ok := h.visiblePost(w, r)
+	if !ok {
+		return
+	}
+
+	replies := new(models.PostReplies)
+	replies.Id = int(post.Id)
+	page, pageErr := pagination.Parse(r.URL.Query(), config.PostRepliesPage, pagination.SinceId)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+	replies.Limit = page.Limit
+	replies.Since = page.Since
+	replies.Desc = page.Desc
+
+	if depth := r.URL.Query().Get("depth"); depth != "" {
+		value, err := strconv.Atoi(depth)
+		if err != nil || value < 0 {
+			message := models.Message{
+				Message: "Invalid depth " + depth + "\n",
+			}
+			response.New(http.StatusBadRequest, message).SendSuccess(w)
+			return
+		}
+		replies.Depth = value
+	}
+
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *replies})
+
+	posts, err := h.postRepo.GetReplies(ctx, replies)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, posts).SendSuccess(w)
+}
+
+func (h *Handler) GetContext(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	post, ok := h.visiblePost(w, r)
+	if !ok {
+		return
+	}
+
+	posts, err := h.postRepo.GetContext(ctx, int(post.Id))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, posts).SendSuccess(w)
+}
@@ -11,13 +11,22 @@ type PostHandler interface {
 	GetDetails(w http.ResponseWriter, r *http.Request)
 	UpdateDetails(w http.ResponseWriter, r *http.Request)
 	CreatePosts(w http.ResponseWriter, r *http.Request)
+	Split(w http.ResponseWriter, r *http.Request)
+	GetReplies(w http.ResponseWriter, r *http.Request)
+	GetContext(w http.ResponseWriter, r *http.Request)
+	GetManyDetails(w http.ResponseWriter, r *http.Request)
 }
 
 type PostRepo interface {
 	GetPost(ctx context.Context, id int) (*models.Post, error)
+	GetPostDetails(ctx context.Context, id int64, related models.PostRelated, viewer string) (*models.InfoPost, error)
+	GetPostsDetails(ctx context.Context, ids []int64, related models.PostRelated, viewer string) ([]models.InfoPost, error)
 	UpdateMessage(ctx context.Context, request *models.MessagePostRequest) error
 	CreatePosts(ctx context.Context, posts *[]models.Post) (*[]models.Post, error)
 	CreateForumsUsers(ctx context.Context, posts *[]models.Post) error
 	GetPostsThread(ctx context.Context, id int) (int, error)
+	GetUserPosts(ctx context.Context, userPosts *models.UserPosts) (*[]models.Post, error)
+	GetReplies(ctx context.Context, replies *models.PostReplies) (*[]models.Post, error)
+	GetContext(ctx context.Context, id int) (*[]models.Post, error)
 	ClearCache()
 }
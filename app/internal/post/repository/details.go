@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/logger"
+	"github.com/forums/utils/prepared"
+	"github.com/jackc/pgx"
+)
+
+// модератором считается владелец форума или роль owner/moderator, $2 - никнейм смотрящего,
+// %s - slug форума проверяемой строки
+const viewerModerates = `$2::citext <> '' AND EXISTS (
+	SELECT 1 FROM forums as f
+	LEFT JOIN forums_roles as fr ON fr.forum = f.slug AND fr.user_nickname = $2::citext
+	WHERE f.slug = %s AND (f.user_create = $2::citext OR fr.role IN ('owner', 'moderator'))
+)`
+
+// Связанные объекты выбираются по id постов, а не по ключам из первого запроса,
+// поэтому все запросы уходят одним batch и не ждут друг друга.
+// Посты и ветки на модерации видны только модераторам их форума.
+var (
+	postsByIds = prepared.Register("posts_by_ids", `
+		SELECT p.id, p.parent, p.user_create, p.message,
+		p.is_edited, p.forum, p.thread, p.created, array_length(p.tree, 1) - 1, p.status = 'pending'
+		FROM posts as p
+		WHERE p.id = ANY($1::bigint[])
+		AND (p.status = 'approved' OR p.status = 'pending' AND `+fmt.Sprintf(viewerModerates, "p.forum")+`)
+	`)
+
+	postsAuthors = prepared.Register("posts_authors", `
+		SELECT u.nickname, u.fullname, u.about, u.email, u.deactivated
+		FROM users as u
+		WHERE u.nickname IN (SELECT p.user_create FROM posts as p WHERE p.id = ANY($1::bigint[]))
+	`)
+
+	postsForums = prepared.Register("posts_forums", `
+		SELECT f.title, f.user_create, f.slug, f.threads, f.posts,
+		f.description, f.archived, f.moderation, f.category, f.parent,
+		f.threads_total, f.posts_total, f.max_depth, f.max_thread_posts, f.depth_policy
+		FROM forums as f
+		WHERE f.slug IN (SELECT p.forum FROM posts as p WHERE p.id = ANY($1::bigint[]))
+	`)
+
+	postsThreads = prepared.Register("posts_threads", `
+		SELECT th.id, th.title, th.user_create, th.forum,
+		th.message, th.slug, th.created, th.votes, th.closed,
+		th.tags, th.posts, th.last_post_at, th.views, th.status = 'pending'
+		FROM threads as th
+		WHERE th.id IN (SELECT p.thread FROM posts as p WHERE p.id = ANY($1::bigint[]))
+		AND (th.status = 'approved' OR th.status = 'pending' AND `+fmt.Sprintf(viewerModerates, "th.forum")+`)
+	`)
+)
+
+// GetPostsDetails отдаёт посты в порядке ids вместе с запрошенными связанными объектами
+// за один поход в базу. Ненайденные и скрытые от viewer посты пропускаются.
+func (r *repo) GetPostsDetails(ctx context.Context, ids []int64, related models.PostRelated, viewer string) ([]models.InfoPost, error) {
+	if len(ids) == 0 {
+		return []models.InfoPost{}, nil
+	}
+
+	batch := r.DB.BeginBatch()
+	defer func() {
+		if err := batch.Close(); err != nil {
+			logger.Repo().AddFuncName("GetPostsDetails_Close").Error(ctx, err)
+		}
+	}()
+
+	batch.Queue(postsByIds, []interface{}{ids, viewer}, nil, nil)
+	if related.User {
+		batch.Queue(postsAuthors, []interface{}{ids}, nil, nil)
+	}
+	if related.Forum {
+		batch.Queue(postsForums, []interface{}{ids}, nil, nil)
+	}
+	if related.Thread {
+		batch.Queue(postsThreads, []interface{}{ids, viewer}, nil, nil)
+	}
+
+	if err := batch.Send(ctx, nil); err != nil {
+		logger.Repo().AddFuncName("GetPostsDetails_Send").Error(ctx, err)
+		return nil, err
+	}
+
+	posts := make(map[int64]*models.Post, len(ids))
+	err := readBatch(batch, func(rows *pgx.Rows) error {
+		post := new(models.Post)
+		if err := rows.Scan(
+			&post.Id,
+			&post.Parent,
+			&post.Author,
+			&post.Message,
+			&post.IsEdited,
+			&post.Forum,
+			&post.Thread,
+			&post.Created,
+			&post.Depth,
+			&post.Pending,
+		); err != nil {
+			return err
+		}
+
+		posts[post.Id] = post
+		return nil
+	})
+	if err != nil {
+		logger.Repo().AddFuncName("GetPostsDetails_Posts").Error(ctx, err)
+		return nil, err
+	}
+
+	// nickname и slug - citext, поэтому ключи без учёта регистра
+	users := make(map[string]*models.User)
+	if related.User {
+		err := readBatch(batch, func(rows *pgx.Rows) error {
+			user := new(models.User)
+			if err := rows.Scan(
+				&user.Nickname,
+				&user.Fullname,
+				&user.About,
+				&user.Email,
+				&user.Deactivated,
+			); err != nil {
+				return err
+			}
+
+			users[strings.ToLower(user.Nickname)] = user
+			return nil
+		})
+		if err != nil {
+			logger.Repo().AddFuncName("GetPostsDetails_Users").Error(ctx, err)
+			return nil, err
+		}
+	}
+
+	forums := make(map[string]*models.Forum)
+	if related.Forum {
+		err := readBatch(batch, func(rows *pgx.Rows) error {
+			forum := new(models.Forum)
+			if err := rows.Scan(
+				&forum.Title,
+				&forum.User,
+				&forum.Slug,
+				&forum.Threads,
+				&forum.Posts,
+				&forum.Description,
+				&forum.Archived,
+				&forum.Moderation,
+				&forum.Category,
+				&forum.Parent,
+				&forum.TotalThreads,
+				&forum.TotalPosts,
+				&forum.MaxDepth,
+				&forum.MaxThreadPosts,
+				&forum.DepthPolicy,
+			); err != nil {
+				return err
+			}
+
+			forums[strings.ToLower(forum.Slug)] = forum
+			return nil
+		})
+		if err != nil {
+			logger.Repo().AddFuncName("GetPostsDetails_Forums").Error(ctx, err)
+			return nil, err
+		}
+	}
+
+	threads := make(map[int]*models.Thread)
+	if related.Thread {
+		err := readBatch(batch, func(rows *pgx.Rows) error {
+			thread := new(models.Thread)
+			if err := rows.Scan(
+				&thread.Id,
+				&thread.Title,
+				&thread.Author,
+				&thread.Forum,
+				&thread.Message,
+				&thread.Slug,
+				&thread.Created,
+				&thread.Votes,
+				&thread.Closed,
+				&thread.Tags,
+				&thread.Posts,
+				&thread.LastPostAt,
+				&thread.Views,
+				&thread.Pending,
+			); err != nil {
+				return err
+			}
+
+			threads[thread.Id] = thread
+			return nil
+		})
+		if err != nil {
+			logger.Repo().AddFuncName("GetPostsDetails_Threads").Error(ctx, err)
+			return nil, err
+		}
+	}
+
+	infos := assembleDetails(ids, posts, users, forums, threads)
+	logger.Repo().Debug(ctx, logger.Fields{"posts details": len(infos)})
+	return infos, nil
+}
+
+// assembleDetails раскладывает связанные объекты по постам в порядке ids,
+// ненайденные посты пропускаются. Ключи users и forums - в нижнем регистре.
+func assembleDetails(
+	ids []int64,
+	posts map[int64]*models.Post,
+	users map[string]*models.User,
+	forums map[string]*models.Forum,
+	threads map[int]*models.Thread,
+) []models.InfoPost {
+	infos := make([]models.InfoPost, 0, len(ids))
+	for _, id := range ids {
+		post, ok := posts[id]
+		if !ok {
+			continue
+		}
+
+		infos = append(infos, models.InfoPost{
+			Post:   post,
+			User:   users[strings.ToLower(post.Author)],
+			Forum:  forums[strings.ToLower(post.Forum)],
+			Thread: threads[post.Thread],
+		})
+	}
+
+	return infos
+}
+
+// GetPostDetails - GetPostsDetails для одного поста, nil если поста нет
+func (r *repo) GetPostDetails(ctx context.Context, id int64, related models.PostRelated, viewer string) (*models.InfoPost, error) {
+	infos, err := r.GetPostsDetails(ctx, []int64{id}, related, viewer)
+	if err != nil || len(infos) == 0 {
+		return nil, err
+	}
+
+	return &infos[0], nil
+}
+
+// readBatch читает результат очередного запроса batch построчно
+func readBatch(batch *pgx.Batch, each func(rows *pgx.Rows) error) error {
+	rows, err := batch.QueryResults()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := each(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/prepared"
+	"github.com/forums/utils/query/querytest"
+	"github.com/jackc/pgx"
+)
+
+// TestDetailsQueries фиксирует тексты и аргументы запросов batch деталей
+func TestDetailsQueries(t *testing.T) {
+	ids := []int64{3, 1}
+	statements := []struct {
+		name string
+		args []interface{}
+	}{
+		{postsByIds, []interface{}{ids, "neo"}},
+		{postsAuthors, []interface{}{ids}},
+		{postsForums, []interface{}{ids}},
+		{postsThreads, []interface{}{ids, "neo"}},
+	}
+
+	cases := make([]querytest.Case, 0, len(statements))
+	for _, s := range statements {
+		cases = append(cases, querytest.Case{
+			Name:  s.name,
+			Query: strings.Join(strings.Fields(prepared.SQL(s.name)), " "),
+			Args:  s.args,
+		})
+	}
+
+	querytest.Golden(t, "details", cases)
+}
+
+func TestAssembleDetails(t *testing.T) {
+	first := &models.Post{Id: 1, Author: "Neo", Forum: "GoLang", Thread: 7}
+	second := &models.Post{Id: 2, Author: "trinity", Forum: "golang", Thread: 8}
+	posts := map[int64]*models.Post{1: first, 2: second}
+	neo := &models.User{Nickname: "neo"}
+	golang := &models.Forum{Slug: "golang"}
+	thread := &models.Thread{Id: 7}
+
+	// порядок ids, ненайденный пост пропускается, ключи без учёта регистра,
+	// отсутствующие связанные объекты остаются nil
+	got := assembleDetails([]int64{2, 5, 1}, posts,
+		map[string]*models.User{"neo": neo},
+		map[string]*models.Forum{"golang": golang},
+		map[int]*models.Thread{7: thread},
+	)
+	want := []models.InfoPost{
+		{Post: second, Forum: golang},
+		{Post: first, User: neo, Forum: golang, Thread: thread},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	// без связанных объектов - только посты
+	got = assembleDetails([]int64{1}, posts, nil, nil, nil)
+	if len(got) != 1 || got[0].Post != first || got[0].User != nil || got[0].Forum != nil || got[0].Thread != nil {
+		t.Errorf("post without related: got %+v", got)
+	}
+
+	if got := assembleDetails([]int64{5}, posts, nil, nil, nil); got == nil || len(got) != 0 {
+		t.Errorf("missing posts: want empty slice, got %#v", got)
+	}
+}
+
+// TestGetPostsDetails гоняет batch деталей постов на живой базе: типы параметров
+// проверяет только сервер. Нужна заполненная база в FORUMS_BENCH_DB.
+//
+//	FORUMS_BENCH_DB=postgres://... go test ./app/internal/post/repository -run Details
+func TestGetPostsDetails(t *testing.T) {
+	uri := os.Getenv("FORUMS_BENCH_DB")
+	if uri == "" {
+		t.Skip("FORUMS_BENCH_DB is not set")
+	}
+
+	configDB, err := pgx.ParseURI(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := pgx.NewConnPool(pgx.ConnPoolConfig{
+		ConnConfig:     configDB,
+		MaxConnections: 1,
+		AfterConnect:   prepared.AfterConnect,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var ids []int64
+	rows, err := db.Query(`
+		SELECT p.id FROM posts as p
+		JOIN threads as th ON th.id = p.thread
+		WHERE p.status = 'approved' AND th.status = 'approved'
+		ORDER BY p.id LIMIT 2
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) == 0 {
+		t.Skip("no approved posts")
+	}
+
+	ctx := context.Background()
+	r := NewPostRepo(db)
+	all := models.PostRelated{User: true, Forum: true, Thread: true}
+
+	// порядок ответа - порядок ids, ненайденный пост пропускается
+	want := []int64{ids[len(ids)-1], ids[0]}
+	infos, err := r.GetPostsDetails(ctx, []int64{want[0], -1, want[1]}, all, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != len(want) {
+		t.Fatalf("want %d posts, got %d", len(want), len(infos))
+	}
+	for i, info := range infos {
+		if info.Post.Id != want[i] {
+			t.Errorf("post #%d: want id %d, got %d", i, want[i], info.Post.Id)
+		}
+		if info.User == nil || info.Forum == nil || info.Thread == nil {
+			t.Errorf("post %d: related objects are missing: %+v", info.Post.Id, info)
+		}
+	}
+
+	info, err := r.GetPostDetails(ctx, ids[0], models.PostRelated{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || info.Post.Id != ids[0] || info.User != nil || info.Forum != nil || info.Thread != nil {
+		t.Errorf("post %d without related: got %+v", ids[0], info)
+	}
+
+	info, err = r.GetPostDetails(ctx, -1, all, "")
+	if err != nil || info != nil {
+		t.Errorf("missing post: want nil, nil, got %+v, %v", info, err)
+	}
+}
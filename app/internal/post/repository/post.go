@@ -7,6 +7,7 @@ import (
 	postModel "github.com/forums/app/internal/post"
 	"github.com/forums/app/models"
 	"github.com/forums/utils/logger"
+	"github.com/forums/utils/prepared"
 	"github.com/jackc/pgx"
 )
 
@@ -58,6 +59,13 @@ func (r *repo) GetPostsThread(ctx context.Context, id int) (int, error) {
 	return thread, nil
 }
 
+var postById = prepared.Register("post_by_id", `
+	SELECT p.id, p.parent, p.user_create, p.message,
+	p.is_edited, p.forum, p.thread, p.created, array_length(p.tree, 1) - 1, p.status = 'pending'
+	FROM posts as p
+	WHERE p.id = $1 AND p.status <> 'rejected'
+`)
+
 func (r *repo) GetPost(ctx context.Context, id int) (*models.Post, error) {
 
 	// r.mutex.Lock()
@@ -68,16 +76,8 @@ func (r *repo) GetPost(ctx context.Context, id int) (*models.Post, error) {
 	// }
 	// r.mutex.Unlock()
 
-	query :=
-		`
-		SELECT p.id, p.parent, p.user_create, p.message, 
-		p.is_edited, p.forum, p.thread, p.created
-		FROM posts as p
-		WHERE p.id = $1
-	`
-
 	post := new(models.Post)
-	err := r.DB.QueryRow(query, id).Scan(
+	err := r.DB.QueryRow(postById, id).Scan(
 		&post.Id,
 		&post.Parent,
 		&post.Author,
@@ -86,6 +86,8 @@ func (r *repo) GetPost(ctx context.Context, id int) (*models.Post, error) {
 		&post.Forum,
 		&post.Thread,
 		&post.Created,
+		&post.Depth,
+		&post.Pending,
 	)
 
 	if err == pgx.ErrNoRows {
@@ -127,20 +129,26 @@ func (r *repo) UpdateMessage(ctx context.Context, request *models.MessagePostReq
 
 func (r *repo) CreatePosts(ctx context.Context, posts *[]models.Post) (*[]models.Post, error) {
 	var queryParams []interface{}
-	query := "INSERT INTO posts (parent, user_create, message, forum, thread, created) VALUES "
+	query := "INSERT INTO posts (parent, user_create, message, forum, thread, created, status) VALUES "
 
 	for i, post := range *posts {
-		query += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)",
-			i*6+1, i*6+2, i*6+3, i*6+4, i*6+5, i*6+6)
+		query += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			i*7+1, i*7+2, i*7+3, i*7+4, i*7+5, i*7+6, i*7+7)
 
 		if i != len(*posts)-1 {
 			query += ","
 		}
 
-		queryParams = append(queryParams, post.Parent, post.Author, post.Message, post.Forum, post.Thread, post.Created)
+		status := models.StatusApproved
+		if post.Pending {
+			status = models.StatusPending
+		}
+
+		queryParams = append(queryParams, post.Parent, post.Author, post.Message, post.Forum, post.Thread, post.Created, status)
 	}
 
-	query += " returning id, created"
+	// parent мог измениться в add_tree при выравнивании глубины, forum - при переносе ветки
+	query += " returning id, parent, forum, created, array_length(tree, 1) - 1"
 
 	logger.Repo().AddFuncName("CreatePosts").Debug(ctx, logger.Fields{"query": query})
 
@@ -154,7 +162,10 @@ func (r *repo) CreatePosts(ctx context.Context, posts *[]models.Post) (*[]models
 	for postsDB.Next() {
 		err = postsDB.Scan(
 			&((*posts)[i].Id),
+			&((*posts)[i].Parent),
+			&((*posts)[i].Forum),
 			&((*posts)[i].Created),
+			&((*posts)[i].Depth),
 		)
 
 		if err != nil {
@@ -198,3 +209,98 @@ func (r *repo) CreateForumsUsers(ctx context.Context, posts *[]models.Post) erro
 
 	return nil
 }
+
+func (r *repo) GetUserPosts(ctx context.Context, userPosts *models.UserPosts) (*[]models.Post, error) {
+	query, queryParams := userPostsQuery(userPosts)
+
+	logger.Repo().AddFuncName("GetUserPosts").Debug(ctx, logger.Fields{"query": query})
+
+	postsDB, err := r.DB.Query(query, queryParams...)
+	if err != nil {
+		logger.Repo().AddFuncName("GetUserPosts").Error(ctx, err)
+		return nil, err
+	}
+	defer postsDB.Close()
+
+	posts := make([]models.Post, 0)
+	for postsDB.Next() {
+		post := new(models.Post)
+		err := postsDB.Scan(
+			&post.Id,
+			&post.Parent,
+			&post.Author,
+			&post.Message,
+			&post.IsEdited,
+			&post.Forum,
+			&post.Thread,
+			&post.Created,
+			&post.Depth,
+			&post.Pending,
+		)
+
+		if err != nil {
+			logger.Repo().AddFuncName("GetUserPosts").Error(ctx, err)
+			return nil, err
+		}
+
+		posts = append(posts, *post)
+	}
+
+	return &posts, nil
+}
+
+func (r *repo) GetReplies(ctx context.Context, replies *models.PostReplies) (*[]models.Post, error) {
+	query, queryParams := repliesQuery(replies)
+
+	logger.Repo().AddFuncName("GetReplies").Debug(ctx, logger.Fields{"query": query})
+	return r.selectPosts(ctx, "GetReplies", query, queryParams...)
+}
+
+// Цепочка предков поста от корня до непосредственного родителя
+func (r *repo) GetContext(ctx context.Context, id int) (*[]models.Post, error) {
+	query :=
+		`
+		SELECT p.id, p.parent, p.user_create, p.message,
+		p.is_edited, p.forum, p.thread, p.created, array_length(p.tree, 1) - 1
+		FROM posts as p
+		JOIN posts AS cur ON cur.id = $1
+		WHERE p.id = ANY(cur.tree) AND p.id <> cur.id AND p.status = 'approved'
+		ORDER BY array_length(p.tree, 1)
+	`
+
+	return r.selectPosts(ctx, "GetContext", query, id)
+}
+
+func (r *repo) selectPosts(ctx context.Context, funcName, query string, queryParams ...interface{}) (*[]models.Post, error) {
+	postsDB, err := r.DB.Query(query, queryParams...)
+	if err != nil {
+		logger.Repo().AddFuncName(funcName).Error(ctx, err)
+		return nil, err
+	}
+	defer postsDB.Close()
+
+	posts := make([]models.Post, 0)
+	for postsDB.Next() {
+		post := new(models.Post)
+		err := postsDB.Scan(
+			&post.Id,
+			&post.Parent,
+			&post.Author,
+			&post.Message,
+			&post.IsEdited,
+			&post.Forum,
+			&post.Thread,
+			&post.Created,
+			&post.Depth,
+		)
+
+		if err != nil {
+			logger.Repo().AddFuncName(funcName).Error(ctx, err)
+			return nil, err
+		}
+
+		posts = append(posts, *post)
+	}
+
+	return &posts, nil
+}
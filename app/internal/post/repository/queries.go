@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"github.com/forums/app/models"
+	"github.com/forums/utils/query"
+)
+
+// Списочные запросы собираются отдельно от выполнения, чтобы их текст проверялся golden-тестами
+
+const selectPosts = `
+	SELECT p.id, p.parent, p.user_create, p.message,
+	p.is_edited, p.forum, p.thread, p.created, array_length(p.tree, 1) - 1
+`
+
+func userPostsQuery(userPosts *models.UserPosts) (string, []interface{}) {
+	q := query.New(selectPosts+", p.status = 'pending' FROM posts as p").
+		Where("p.user_create = ?", userPosts.Nickname)
+
+	if !userPosts.IncludeHidden {
+		q.Where("p.status = 'approved'")
+	}
+
+	if userPosts.Forum != "" {
+		q.Where("p.forum = ?", userPosts.Forum)
+	}
+
+	return q.Desc(userPosts.Desc).
+		Since("p.id", "?", userPosts.Since).
+		OrderBy("p.id").
+		Limit(userPosts.Limit).
+		Build()
+}
+
+func repliesQuery(replies *models.PostReplies) (string, []interface{}) {
+	q := query.New(selectPosts+" FROM posts as p JOIN posts AS root ON root.id = ?", replies.Id).
+		Where("p.thread = root.thread").
+		Where("p.tree @> ARRAY[root.id]").
+		Where("p.id <> root.id").
+		Where("p.status = 'approved'")
+
+	if replies.Depth > 0 {
+		q.Where("array_length(p.tree, 1) <= array_length(root.tree, 1) + ?", replies.Depth)
+	}
+
+	return q.Desc(replies.Desc).
+		Since("p.tree", "(SELECT p2.tree FROM posts AS p2 WHERE p2.id = ?)", replies.Since).
+		OrderBy("p.tree").
+		Limit(replies.Limit).
+		Build()
+}
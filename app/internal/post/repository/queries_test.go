@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/query/querytest"
+)
+
+func TestUserPostsQuery(t *testing.T) {
+	axes := []querytest.Axis{
+		{Name: "hidden", Values: []interface{}{false, true}},
+		querytest.Strings("forum", "", "golang"),
+		querytest.Desc(), querytest.Since("42"), querytest.Limit(),
+	}
+
+	querytest.Golden(t, "user_posts", querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+		return userPostsQuery(&models.UserPosts{
+			Nickname: "neo", IncludeHidden: p.Bool("hidden"), Forum: p.String("forum"),
+			Desc: p.Bool("desc"), Since: p.String("since"), Limit: p.Int("limit"),
+		})
+	}))
+}
+
+func TestRepliesQuery(t *testing.T) {
+	axes := []querytest.Axis{
+		{Name: "depth", Values: []interface{}{0, 2}},
+		querytest.Desc(), querytest.Since("42"), querytest.Limit(),
+	}
+
+	querytest.Golden(t, "replies", querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+		return repliesQuery(&models.PostReplies{
+			Id: 7, Depth: p.Int("depth"), Desc: p.Bool("desc"), Since: p.String("since"), Limit: p.Int("limit"),
+		})
+	}))
+}
@@ -0,0 +1,419 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/forums/app/config"
+	forumModel "github.com/forums/app/internal/forum"
+	postModel "github.com/forums/app/internal/post"
+	reportModel "github.com/forums/app/internal/report"
+	threadModel "github.com/forums/app/internal/thread"
+	userModel "github.com/forums/app/internal/user"
+	"github.com/forums/app/models"
+	"github.com/forums/utils/errors"
+	"github.com/forums/utils/logger"
+	"github.com/forums/utils/pagination"
+	"github.com/forums/utils/response"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	reportRepo reportModel.ReportRepo
+	forumRepo  forumModel.ForumRepo
+	postRepo   postModel.PostRepo
+	threadRepo threadModel.ThreadRepo
+	userRepo   userModel.UserRepo
+}
+
+func NewReportHandler(reportRepo reportModel.ReportRepo, forumRepo forumModel.ForumRepo,
+	postRepo postModel.PostRepo, threadRepo threadModel.ThreadRepo, userRepo userModel.UserRepo) reportModel.ReportHandler {
+	return &Handler{
+		reportRepo: reportRepo,
+		forumRepo:  forumRepo,
+		postRepo:   postRepo,
+		threadRepo: threadRepo,
+		userRepo:   userRepo,
+	}
+}
+
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request) *models.ReportRequest {
+	ctx := r.Context()
+
+	request := new(models.ReportRequest)
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return nil
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *request})
+
+	if strings.TrimSpace(request.Reason) == "" {
+		message := models.Message{
+			Message: "Report reason is required\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return nil
+	}
+
+	return request
+}
+
+func (h *Handler) createReport(w http.ResponseWriter, r *http.Request, report *models.Report) {
+	ctx := r.Context()
+
+	reporter, err := h.userRepo.GetUserByName(ctx, report.Reporter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if reporter == nil {
+		message := models.Message{
+			Message: "Can't find user with id #" + report.Reporter + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+	if reporter.Deactivated {
+		message := models.Message{
+			Message: "User is deactivated\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	report.Reporter = reporter.Nickname
+	err = h.reportRepo.CreateReport(ctx, report)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusCreated, report).SendSuccess(w)
+}
+
+func (h *Handler) ReportPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+
+	request := h.decodeRequest(w, r)
+	if request == nil {
+		return
+	}
+
+	post, err := h.postRepo.GetPost(ctx, id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if post == nil {
+		message := models.Message{
+			Message: "Can't find post with id #" + vars["id"] + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	h.createReport(w, r, &models.Report{
+		Kind:     models.ReportPost,
+		Forum:    post.Forum,
+		Reporter: request.Reporter,
+		Reason:   request.Reason,
+		Post:     &post.Id,
+	})
+}
+
+func (h *Handler) ReportThread(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slugOrId := vars["slug_or_id"]
+
+	request := h.decodeRequest(w, r)
+	if request == nil {
+		return
+	}
+
+	thread, err := h.threadRepo.GetThreadBySlugOrId(ctx, slugOrId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if thread == nil {
+		message := models.Message{
+			Message: "Can't find thread with id #" + slugOrId + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	h.createReport(w, r, &models.Report{
+		Kind:     models.ReportThread,
+		Forum:    thread.Forum,
+		Reporter: request.Reporter,
+		Reason:   request.Reason,
+		Thread:   &thread.Id,
+	})
+}
+
+func (h *Handler) ReportUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	nickname := vars["nickname"]
+
+	request := h.decodeRequest(w, r)
+	if request == nil {
+		return
+	}
+
+	user, err := h.userRepo.GetUserByName(ctx, nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil || user.Deactivated {
+		message := models.Message{
+			Message: "Can't find user with id #" + nickname + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	// жалобу на профиль разбирают модераторы того форума, где было нарушение
+	forum, err := h.forumRepo.GetForumBySlug(ctx, request.Forum)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + request.Forum + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	h.createReport(w, r, &models.Report{
+		Kind:     models.ReportUser,
+		Forum:    forum.Slug,
+		Reporter: request.Reporter,
+		Reason:   request.Reason,
+		User:     &user.Nickname,
+	})
+}
+
+func (h *Handler) GetReports(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	page, pageErr := pagination.Parse(r.URL.Query(), config.ReportsPage, pagination.SinceString)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+	limit := page.Limit
+
+	forum := forumModel.ModeratedForum(w, r, h.forumRepo)
+	if forum == nil {
+		return
+	}
+	logger.Delivery().Info(ctx, logger.Fields{"request data": forum.Slug, "limit": limit})
+
+	reports, err := h.reportRepo.GetOpenReports(ctx, forum.Slug, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	infoReports := make([]models.InfoReport, 0, len(*reports))
+	for i := range *reports {
+		report := &(*reports)[i]
+		infoReport := models.InfoReport{
+			Report: report,
+		}
+
+		switch report.Kind {
+		case models.ReportPost:
+			infoReport.Post, err = h.postRepo.GetPost(ctx, int(*report.Post))
+		case models.ReportThread:
+			infoReport.Thread, err = h.threadRepo.GetThreadBySlugOrId(ctx, strconv.Itoa(*report.Thread))
+		case models.ReportUser:
+			infoReport.User, err = h.userRepo.GetUserByName(ctx, *report.User)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		infoReports = append(infoReports, infoReport)
+	}
+
+	response.New(http.StatusOK, infoReports).SendSuccess(w)
+}
+
+// reportedAuthor возвращает автора содержимого, на которое пожаловались
+func (h *Handler) reportedAuthor(r *http.Request, report *models.Report) (string, error) {
+	ctx := r.Context()
+
+	switch report.Kind {
+	case models.ReportPost:
+		post, err := h.postRepo.GetPost(ctx, int(*report.Post))
+		if err != nil || post == nil {
+			return "", err
+		}
+		return post.Author, nil
+	case models.ReportThread:
+		thread, err := h.threadRepo.GetThreadBySlugOrId(ctx, strconv.Itoa(*report.Thread))
+		if err != nil || thread == nil {
+			return "", err
+		}
+		return thread.Author, nil
+	default:
+		return *report.User, nil
+	}
+}
+
+func (h *Handler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+
+	action := new(models.ResolveReportRequest)
+	err = json.NewDecoder(r.Body).Decode(&action)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *action, "id": id})
+
+	switch action.Action {
+	case models.ReportActionDismiss, models.ReportActionResolve, models.ReportActionHide, models.ReportActionBan:
+	default:
+		message := models.Message{
+			Message: "Unknown action #" + action.Action + "\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
+	forum := forumModel.ModeratedForum(w, r, h.forumRepo)
+	if forum == nil {
+		return
+	}
+
+	report, err := h.reportRepo.GetReport(ctx, forum.Slug, id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if report == nil || report.Status != models.ReportOpen {
+		message := models.Message{
+			Message: "Can't find open report with id #" + vars["id"] + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	switch action.Action {
+	case models.ReportActionHide:
+		switch report.Kind {
+		case models.ReportPost:
+			_, err = h.forumRepo.HidePost(ctx, forum.Slug, *report.Post)
+		case models.ReportThread:
+			_, err = h.forumRepo.HideThread(ctx, forum.Slug, *report.Thread)
+		default:
+			message := models.Message{
+				Message: "Can't hide user profile, use ban\n",
+			}
+			response.New(http.StatusBadRequest, message).SendSuccess(w)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+	case models.ReportActionBan:
+		author, err := h.reportedAuthor(r, report)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if author == "" {
+			message := models.Message{
+				Message: "Reported item no longer exists\n",
+			}
+			response.New(http.StatusNotFound, message).SendSuccess(w)
+			return
+		}
+
+		role, err := h.forumRepo.GetRole(ctx, forum.Slug, author)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if models.IsModerator(role) {
+			message := models.Message{
+				Message: "Can't ban moderator #" + author + "\n",
+			}
+			response.New(http.StatusForbidden, message).SendSuccess(w)
+			return
+		}
+
+		err = h.forumRepo.SetRole(ctx, &models.ForumRole{
+			Forum:    forum.Slug,
+			Nickname: author,
+			Role:     models.RoleBanned,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	actor := r.URL.Query().Get("user")
+	report.Status = models.ReportResolved
+	if action.Action == models.ReportActionDismiss {
+		report.Status = models.ReportDismissed
+	}
+	report.ResolvedBy = &actor
+	report.Action = action.Action
+
+	found, err := h.reportRepo.ResolveReport(ctx, report)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		message := models.Message{
+			Message: "Can't find open report with id #" + vars["id"] + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	response.New(http.StatusOK, report).SendSuccess(w)
+}
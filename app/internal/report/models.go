@@ -0,0 +1,23 @@
+package report
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/forums/app/models"
+)
+
+type ReportHandler interface {
+	ReportPost(w http.ResponseWriter, r *http.Request)
+	ReportThread(w http.ResponseWriter, r *http.Request)
+	ReportUser(w http.ResponseWriter, r *http.Request)
+	GetReports(w http.ResponseWriter, r *http.Request)
+	ResolveReport(w http.ResponseWriter, r *http.Request)
+}
+
+type ReportRepo interface {
+	CreateReport(ctx context.Context, report *models.Report) error
+	GetOpenReports(ctx context.Context, slug string, limit int) (*[]models.Report, error)
+	GetReport(ctx context.Context, slug string, id int) (*models.Report, error)
+	ResolveReport(ctx context.Context, report *models.Report) (bool, error)
+}
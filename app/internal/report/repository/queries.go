@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"github.com/forums/utils/query"
+)
+
+// Списочные запросы собираются отдельно от выполнения, чтобы их текст проверялся golden-тестами
+
+func openReportsQuery(slug string, limit int) (string, []interface{}) {
+	return query.New(selectReport).
+		Where("rp.forum = ?", slug).
+		Where("rp.status = 'open'").
+		OrderBy("rp.id").
+		Limit(limit).
+		Build()
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/forums/utils/query/querytest"
+)
+
+func TestOpenReportsQuery(t *testing.T) {
+	querytest.Golden(t, "open_reports", querytest.Matrix([]querytest.Axis{querytest.Limit()}, func(p querytest.Params) (string, []interface{}) {
+		return openReportsQuery("golang", p.Int("limit"))
+	}))
+}
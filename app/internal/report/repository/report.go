@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+
+	reportModel "github.com/forums/app/internal/report"
+	"github.com/forums/app/models"
+	"github.com/forums/utils/logger"
+	"github.com/jackc/pgx"
+)
+
+type repo struct {
+	DB *pgx.ConnPool
+}
+
+func NewReportRepo(db *pgx.ConnPool) reportModel.ReportRepo {
+	return &repo{
+		DB: db,
+	}
+}
+
+const selectReport = `
+	SELECT rp.id, rp.kind, rp.forum, rp.reporter, rp.reason, rp.status,
+	rp.post, rp.thread, rp.user_nickname, rp.created, rp.resolved_by, rp.action
+	FROM reports as rp
+`
+
+// общий Scan для *pgx.Row и *pgx.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanReport(row scanner, report *models.Report) error {
+	return row.Scan(
+		&report.Id,
+		&report.Kind,
+		&report.Forum,
+		&report.Reporter,
+		&report.Reason,
+		&report.Status,
+		&report.Post,
+		&report.Thread,
+		&report.User,
+		&report.Created,
+		&report.ResolvedBy,
+		&report.Action,
+	)
+}
+
+func (r *repo) CreateReport(ctx context.Context, report *models.Report) error {
+	query :=
+		`
+		INSERT INTO reports (kind, forum, reporter, reason, post, thread, user_nickname)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, created
+	`
+
+	err := r.DB.QueryRow(query,
+		report.Kind,
+		report.Forum,
+		report.Reporter,
+		report.Reason,
+		report.Post,
+		report.Thread,
+		report.User).Scan(&report.Id, &report.Status, &report.Created)
+
+	if err != nil {
+		logger.Repo().AddFuncName("CreateReport").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) GetOpenReports(ctx context.Context, slug string, limit int) (*[]models.Report, error) {
+	query, queryParams := openReportsQuery(slug, limit)
+
+	reportsDB, err := r.DB.Query(query, queryParams...)
+	if err != nil {
+		logger.Repo().AddFuncName("GetOpenReports").Error(ctx, err)
+		return nil, err
+	}
+	defer reportsDB.Close()
+
+	reports := make([]models.Report, 0)
+	for reportsDB.Next() {
+		report := new(models.Report)
+		err := scanReport(reportsDB, report)
+		if err != nil {
+			logger.Repo().AddFuncName("GetOpenReports").Error(ctx, err)
+			return nil, err
+		}
+
+		reports = append(reports, *report)
+	}
+
+	return &reports, nil
+}
+
+func (r *repo) GetReport(ctx context.Context, slug string, id int) (*models.Report, error) {
+	query := selectReport + `
+		WHERE rp.forum = $1 AND rp.id = $2
+	`
+
+	report := new(models.Report)
+	err := scanReport(r.DB.QueryRow(query, slug, id), report)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+
+		logger.Repo().AddFuncName("GetReport").Error(ctx, err)
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (r *repo) ResolveReport(ctx context.Context, report *models.Report) (bool, error) {
+	query :=
+		`
+		UPDATE reports SET status = $1, resolved_by = $2, action = $3
+		WHERE id = $4 AND status = 'open'
+	`
+
+	result, err := r.DB.Exec(query, report.Status, report.ResolvedBy, report.Action, report.Id)
+	if err != nil {
+		logger.Repo().AddFuncName("ResolveReport").Error(ctx, err)
+		return false, err
+	}
+
+	return result.RowsAffected() != 0, nil
+}
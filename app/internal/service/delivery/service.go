@@ -39,3 +39,15 @@ func (h *Handler) StatusDb(w http.ResponseWriter, r *http.Request) {
 
 	response.SendSuccess(w)
 }
+
+func (h *Handler) ResyncForumsUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	response, err := h.serviceUsecase.ResyncForumsUsers(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.SendSuccess(w)
+}
@@ -11,14 +11,17 @@ import (
 type ServiceHandler interface {
 	ClearDb(w http.ResponseWriter, r *http.Request)
 	StatusDb(w http.ResponseWriter, r *http.Request)
+	ResyncForumsUsers(w http.ResponseWriter, r *http.Request)
 }
 
 type ServiceUsecase interface {
 	ClearDb(ctx context.Context) error
 	StatusDb(ctx context.Context) (response.Response, error)
+	ResyncForumsUsers(ctx context.Context) (response.Response, error)
 }
 
 type ServiceRepo interface {
 	ClearDb(ctx context.Context) error
 	StatusDb(ctx context.Context) (*models.InfoStatus, error)
+	ResyncForumsUsers(ctx context.Context) (int64, error)
 }
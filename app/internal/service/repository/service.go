@@ -23,7 +23,7 @@ func (r *repo) ClearDb(ctx context.Context) error {
 
 	query :=
 		`
-		TRUNCATE users, forums, threads, posts, forums_users, votes CASCADE
+		TRUNCATE users, users_aliases, forums, forums_aliases, threads_aliases, forums_roles, forums_filters, reports, threads_reads, threads, posts, forums_users, votes CASCADE
 	`
 	result, err := r.DB.Exec(query)
 	if err != nil {
@@ -35,6 +35,28 @@ func (r *repo) ClearDb(ctx context.Context) error {
 	return nil
 }
 
+func (r *repo) ResyncForumsUsers(ctx context.Context) (int64, error) {
+	query :=
+		`
+		UPDATE forums_users AS fu
+		SET user_fullname = u.fullname, user_about = u.about, user_email = u.email
+		FROM users AS u
+		WHERE fu.user_nickname = u.nickname AND (
+			fu.user_fullname IS DISTINCT FROM u.fullname OR
+			fu.user_about IS DISTINCT FROM u.about OR
+			fu.user_email IS DISTINCT FROM u.email
+		)
+	`
+	result, err := r.DB.Exec(query)
+	if err != nil {
+		logger.Repo().AddFuncName("ResyncForumsUsers").Error(ctx, err)
+		return 0, err
+	}
+
+	logger.Repo().Info(ctx, logger.Fields{"result": result})
+	return result.RowsAffected(), nil
+}
+
 func (r *repo) StatusDb(ctx context.Context) (*models.InfoStatus, error) {
 	// TODO: возможно сделать не прямой запрос кол-ва а через другие средства
 	info := new(models.InfoStatus)
@@ -6,6 +6,7 @@ import (
 
 	postModel "github.com/forums/app/internal/post"
 	serviceModel "github.com/forums/app/internal/service"
+	"github.com/forums/app/models"
 	"github.com/forums/utils/response"
 )
 
@@ -35,3 +36,13 @@ func (u *usecase) StatusDb(ctx context.Context) (response.Response, error) {
 	response := response.New(http.StatusOK, result)
 	return response, nil
 }
+
+func (u *usecase) ResyncForumsUsers(ctx context.Context) (response.Response, error) {
+	changed, err := u.serviceRepo.ResyncForumsUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := response.New(http.StatusOK, models.InfoResync{Changed: changed})
+	return response, nil
+}
@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/response"
+)
+
+const FormatNested = "nested"
+
+// nestedWriter пишет посты, пришедшие в порядке обхода дерева (родитель раньше детей),
+// сразу вложенным JSON без построения дерева в памяти. Держит только путь от корня.
+// Пост, родитель которого остался на прошлой странице, выводится на верхнем уровне
+// со своим parent, чтобы клиент мог пристроить его к уже полученному дереву.
+// Код ответа, сброс буфера и обработку ошибок берёт на себя response.Stream.
+type nestedWriter struct {
+	stream *response.Stream
+	path   []int64
+	empty  bool // у текущего уровня ещё нет элементов
+}
+
+func newNestedWriter(w http.ResponseWriter) *nestedWriter {
+	return &nestedWriter{
+		stream: response.NewStream(w, http.StatusOK),
+		empty:  true,
+	}
+}
+
+func (n *nestedWriter) Write(post *models.Post) error {
+	for len(n.path) != 0 && (post.Parent == nil || *post.Parent != n.path[len(n.path)-1]) {
+		n.path = n.path[:len(n.path)-1]
+		if err := n.stream.WriteRaw([]byte("]}")); err != nil {
+			return err
+		}
+		n.empty = false
+	}
+
+	body, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+
+	if !n.empty {
+		if err := n.stream.WriteRaw([]byte{','}); err != nil {
+			return err
+		}
+	}
+	if err := n.stream.WriteRaw(body[:len(body)-1], []byte(`,"children":[`)); err != nil {
+		return err
+	}
+
+	n.path = append(n.path, post.Id)
+	n.empty = true
+	return n.stream.Item()
+}
+
+func (n *nestedWriter) Close() error {
+	for range n.path {
+		if err := n.stream.WriteRaw([]byte("]}")); err != nil {
+			return err
+		}
+	}
+	n.path = nil
+
+	return n.stream.Close()
+}
+
+// End - как у response.Stream: 500 до первого поста, обрыв ответа после
+func (n *nestedWriter) End(err error) error {
+	if err != nil {
+		return n.stream.End(err)
+	}
+
+	return n.Close()
+}
+
+// nestedPost нужен только для sort=tree&desc=true: там дети идут раньше родителя,
+// поэтому страницу приходится собирать в памяти
+type nestedPost struct {
+	models.Post
+	Children []nestedPost `json:"children"`
+}
+
+func nestReversed(posts []models.Post) []nestedPost {
+	stack := make([]nestedPost, 0)
+	for _, post := range posts {
+		first := len(stack)
+		for first > 0 && stack[first-1].Parent != nil && *stack[first-1].Parent == post.Id {
+			first--
+		}
+
+		node := nestedPost{
+			Post:     post,
+			Children: append(make([]nestedPost, 0, len(stack)-first), stack[first:]...),
+		}
+		stack = append(stack[:first], node)
+	}
+
+	return stack
+}
@@ -3,13 +3,18 @@ package delivery
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/forums/app/config"
+	filterModel "github.com/forums/app/internal/filter"
 	forumModel "github.com/forums/app/internal/forum"
 	threadModel "github.com/forums/app/internal/thread"
 	userModel "github.com/forums/app/internal/user"
 	"github.com/forums/app/models"
 	"github.com/forums/utils/errors"
 	"github.com/forums/utils/logger"
+	"github.com/forums/utils/pagination"
 	"github.com/forums/utils/response"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgerrcode"
@@ -17,17 +22,19 @@ import (
 )
 
 type Handler struct {
-	threadRepo threadModel.ThreadRepo
-	userRepo   userModel.UserRepo
-	forumRepo  forumModel.ForumRepo
+	threadRepo    threadModel.ThreadRepo
+	userRepo      userModel.UserRepo
+	forumRepo     forumModel.ForumRepo
+	filterUsecase filterModel.FilterUsecase
 }
 
 func NewThreadHandler(threadRepo threadModel.ThreadRepo, userRepo userModel.UserRepo,
-	forumRepo forumModel.ForumRepo) threadModel.ThreadHandler {
+	forumRepo forumModel.ForumRepo, filterUsecase filterModel.FilterUsecase) threadModel.ThreadHandler {
 	return &Handler{
-		threadRepo: threadRepo,
-		userRepo:   userRepo,
-		forumRepo:  forumRepo,
+		threadRepo:    threadRepo,
+		userRepo:      userRepo,
+		forumRepo:     forumRepo,
+		filterUsecase: filterUsecase,
 	}
 }
 
@@ -45,6 +52,16 @@ func (h *Handler) CreateThread(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	logger.Delivery().Info(ctx, logger.Fields{"request data": *newThread})
 
+	tags, ok := models.NormalizeTags(newThread.Tags)
+	if !ok {
+		message := models.Message{
+			Message: "Too many or too long tags\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+	newThread.Tags = tags
+
 	user, err := h.userRepo.GetUserByName(ctx, newThread.Author)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -63,6 +80,13 @@ func (h *Handler) CreateThread(w http.ResponseWriter, r *http.Request) {
 		response.New(http.StatusNotFound, message).SendSuccess(w)
 		return
 	}
+	if user.Deactivated {
+		message := models.Message{
+			Message: "User is deactivated\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
 	if forum == nil {
 		message := models.Message{
 			Message: "Can't find forum with id #" + slug + "\n",
@@ -70,8 +94,28 @@ func (h *Handler) CreateThread(w http.ResponseWriter, r *http.Request) {
 		response.New(http.StatusNotFound, message).SendSuccess(w)
 		return
 	}
+	if forum.Archived {
+		message := models.Message{
+			Message: "Forum #" + forum.Slug + " is archived\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
 
-	oldThread, err := h.threadRepo.GetThreadBySlugOrId(ctx, newThread.Slug)
+	role, err := h.forumRepo.GetRole(ctx, forum.Slug, user.Nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if role == models.RoleBanned {
+		message := models.Message{
+			Message: "User #" + user.Nickname + " is banned in forum #" + forum.Slug + "\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	oldThread, err := h.threadRepo.GetThread(ctx, newThread.Slug)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -81,7 +125,27 @@ func (h *Handler) CreateThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	content := &models.FilterContent{
+		Forum:   forum.Slug,
+		Author:  user.Nickname,
+		Title:   newThread.Title,
+		Message: newThread.Message,
+		Thread:  true,
+	}
+	rejection, err := h.filterUsecase.Check(ctx, []*models.FilterContent{content})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if rejection != nil {
+		response.New(http.StatusBadRequest, models.Message{Message: rejection.Message}).SendSuccess(w)
+		return
+	}
+
+	newThread.Title = content.Title
+	newThread.Message = content.Message
 	newThread.Forum = forum.Slug
+	newThread.Pending = forum.Moderation || content.Queued
 	id, err := h.threadRepo.CreateThread(ctx, newThread)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -144,6 +208,11 @@ func (h *Handler) UpdateDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	actor := r.URL.Query().Get("user")
+	if !forumModel.CheckEditor(w, r, h.forumRepo, threadOld.Forum, threadOld.Author, actor, "thread #"+strconv.Itoa(threadOld.Id)) {
+		return
+	}
+
 	if newThread.Title != "" {
 		threadOld.Title = newThread.Title
 	}
@@ -152,7 +221,44 @@ func (h *Handler) UpdateDetails(w http.ResponseWriter, r *http.Request) {
 		threadOld.Message = newThread.Message
 	}
 
-	err = h.threadRepo.UpdateThreadBySlug(ctx, threadOld)
+	// отсутствие tags оставляет теги как есть, пустой массив их очищает
+	if newThread.Tags != nil {
+		tags, ok := models.NormalizeTags(newThread.Tags)
+		if !ok {
+			message := models.Message{
+				Message: "Too many or too long tags\n",
+			}
+			response.New(http.StatusBadRequest, message).SendSuccess(w)
+			return
+		}
+		threadOld.Tags = tags
+	}
+
+	if newThread.Title != "" || newThread.Message != "" {
+		content := &models.FilterContent{
+			Id:      int64(threadOld.Id),
+			Forum:   threadOld.Forum,
+			Author:  threadOld.Author,
+			Title:   threadOld.Title,
+			Message: threadOld.Message,
+			Thread:  true,
+			Edit:    true,
+		}
+		rejection, err := h.filterUsecase.Check(ctx, []*models.FilterContent{content})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if rejection != nil {
+			response.New(http.StatusBadRequest, models.Message{Message: rejection.Message}).SendSuccess(w)
+			return
+		}
+
+		threadOld.Title = content.Title
+		threadOld.Message = content.Message
+	}
+
+	err = h.threadRepo.UpdateThread(ctx, threadOld)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -168,18 +274,28 @@ func (h *Handler) GetPosts(w http.ResponseWriter, r *http.Request) {
 	threadPosts := new(models.ThreadPosts)
 
 	threadPosts.SlugOrId = vars["slug_or_id"]
-	threadPosts.Limit = r.URL.Query().Get("limit")
-	threadPosts.Since = r.URL.Query().Get("since")
 	threadPosts.Sort = r.URL.Query().Get("sort")
+	threadPosts.Viewer = r.URL.Query().Get("viewer")
+	format := r.URL.Query().Get("format")
 
-	desc := r.URL.Query().Get("desc")
-	if desc == "false" || desc == "" {
-		threadPosts.Desc = false
-	} else {
-		threadPosts.Desc = true
+	page, pageErr := pagination.Parse(r.URL.Query(), config.ThreadPostsPage, pagination.SinceId)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
 	}
+	threadPosts.Limit = page.Limit
+	threadPosts.Since = page.Since
+	threadPosts.Desc = page.Desc
+
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *threadPosts, "format": format})
 
-	logger.Delivery().Info(ctx, logger.Fields{"request data": *threadPosts})
+	if format != "" && (format != FormatNested || (threadPosts.Sort != "tree" && threadPosts.Sort != "parent_tree")) {
+		message := models.Message{
+			Message: "Format " + format + " is not supported for sort " + threadPosts.Sort + "\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
 
 	thread, err := h.threadRepo.GetThreadBySlugOrId(ctx, threadPosts.SlugOrId)
 	if err != nil {
@@ -196,13 +312,124 @@ func (h *Handler) GetPosts(w http.ResponseWriter, r *http.Request) {
 	}
 
 	threadPosts.ThreadId = thread.Id
-	posts, err := h.threadRepo.GetPosts(ctx, threadPosts)
+
+	// ответ уходит по мере чтения строк, поэтому читателя проверяем заранее
+	if threadPosts.Viewer != "" {
+		user, err := h.userRepo.GetUserByName(ctx, threadPosts.Viewer)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			message := models.Message{
+				Message: "Can't find user with id #" + threadPosts.Viewer + "\n",
+			}
+			response.New(http.StatusNotFound, message).SendSuccess(w)
+			return
+		}
+		threadPosts.Viewer = user.Nickname
+	}
+
+	lastRead := int64(0)
+	track := func(post *models.Post) {
+		if post.Id > lastRead {
+			lastRead = post.Id
+		}
+	}
+
+	switch {
+	case format == FormatNested && threadPosts.Sort == "tree" && threadPosts.Desc:
+		// дети идут раньше родителя, страницу приходится собрать в памяти
+		posts := make([]models.Post, 0)
+		err = h.threadRepo.GetPosts(ctx, threadPosts, func(post *models.Post) error {
+			track(post)
+			posts = append(posts, *post)
+			return nil
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		response.New(http.StatusOK, nestReversed(posts)).SendSuccess(w)
+
+	case format == FormatNested:
+		nested := newNestedWriter(w)
+		err = h.threadRepo.GetPosts(ctx, threadPosts, func(post *models.Post) error {
+			track(post)
+			return nested.Write(post)
+		})
+		nested.End(err)
+
+	default:
+		stream := response.NewStream(w, http.StatusOK)
+		err = h.threadRepo.GetPosts(ctx, threadPosts, func(post *models.Post) error {
+			track(post)
+			return stream.Write(post)
+		})
+		stream.End(err)
+	}
+
+	if err != nil {
+		return
+	}
+
+	// просмотром считается только первая страница
+	if threadPosts.Since == "" {
+		h.threadRepo.AddView(thread.Id)
+	}
+
+	if threadPosts.Viewer != "" && lastRead != 0 {
+		if _, err := h.threadRepo.MarkRead(ctx, threadPosts.Viewer, thread.Id, lastRead); err != nil {
+			logger.Delivery().AddFuncName("GetPosts").Error(ctx, err)
+		}
+	}
+}
+
+func (h *Handler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slugOrId := vars["slug_or_id"]
+	read := new(models.ReadThreadRequest)
+	err := json.NewDecoder(r.Body).Decode(&read)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *read, "slug_or_id": slugOrId})
+
+	thread, err := h.threadRepo.GetThreadBySlugOrId(ctx, slugOrId)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if thread == nil {
+		message := models.Message{
+			Message: "Can't find thread with id #" + slugOrId + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
 
-	response.New(http.StatusOK, posts).SendSuccess(w)
+	state, err := h.threadRepo.MarkRead(ctx, read.Nickname, thread.Id, read.Post)
+	if err != nil {
+		if pqErr, ok := err.(pgx.PgError); ok && pqErr.Code == pgerrcode.ForeignKeyViolation {
+			message := models.Message{
+				Message: "Can't find user with id #" + read.Nickname + "\n",
+			}
+			response.New(http.StatusNotFound, message).SendSuccess(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, state).SendSuccess(w)
 }
 
 func (h *Handler) Vote(w http.ResponseWriter, r *http.Request) {
@@ -248,9 +475,23 @@ func (h *Handler) Vote(w http.ResponseWriter, r *http.Request) {
 				response.New(http.StatusNotFound, message).SendSuccess(w)
 				return
 
+			case "12346": // пользователь деактивирован
+				message := models.Message{
+					Message: "User is deactivated\n",
+				}
+				response.New(http.StatusForbidden, message).SendSuccess(w)
+				return
+
 			case pgerrcode.UniqueViolation: // уже есть в бд, надо обновить
 				err = h.threadRepo.UpdateVote(ctx, vote)
 				if err != nil {
+					if pqErr, ok := err.(pgx.PgError); ok && pqErr.Code == "12346" {
+						message := models.Message{
+							Message: "User is deactivated\n",
+						}
+						response.New(http.StatusForbidden, message).SendSuccess(w)
+						return
+					}
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
@@ -273,3 +514,248 @@ func (h *Handler) Vote(w http.ResponseWriter, r *http.Request) {
 
 	response.New(http.StatusOK, thread).SendSuccess(w)
 }
+
+func (h *Handler) Close(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slugOrId := vars["slug_or_id"]
+	actor := r.URL.Query().Get("user")
+	closeThread := new(models.CloseThreadRequest)
+	err := json.NewDecoder(r.Body).Decode(&closeThread)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *closeThread, "slug_or_id": slugOrId, "user": actor})
+
+	thread, err := h.threadRepo.GetThreadBySlugOrId(ctx, slugOrId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if thread == nil {
+		message := models.Message{
+			Message: "Can't find thread with id #" + slugOrId + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	if !forumModel.CheckModerator(w, r, h.forumRepo, thread.Forum, actor) {
+		return
+	}
+
+	err = h.threadRepo.SetClosed(ctx, thread.Id, closeThread.Closed)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	thread.Closed = closeThread.Closed
+	response.New(http.StatusOK, thread).SendSuccess(w)
+}
+
+func (h *Handler) GetTagThreads(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	tagThreads := &models.TagThreads{
+		Tag: strings.ToLower(strings.TrimSpace(vars["tag"])),
+	}
+
+	page, pageErr := pagination.Parse(r.URL.Query(), config.TagThreadsPage, pagination.SinceTime)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+	tagThreads.Limit = page.Limit
+	tagThreads.Since = page.Since
+	tagThreads.Desc = page.Desc
+
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *tagThreads})
+
+	threads, err := h.threadRepo.GetTagThreads(ctx, tagThreads)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, threads).SendSuccess(w)
+}
+
+// approvedThread ищет ветку, которую можно переносить: существующую и уже одобренную
+func (h *Handler) approvedThread(w http.ResponseWriter, r *http.Request, slugOrId string) *models.Thread {
+	ctx := r.Context()
+
+	thread, err := h.threadRepo.GetThreadBySlugOrId(ctx, slugOrId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil
+	}
+	if thread == nil {
+		message := models.Message{
+			Message: "Can't find thread with id #" + slugOrId + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return nil
+	}
+	if thread.Pending {
+		message := models.Message{
+			Message: "Thread #" + strconv.Itoa(thread.Id) + " is awaiting moderation\n",
+		}
+		response.New(http.StatusConflict, message).SendSuccess(w)
+		return nil
+	}
+
+	return thread
+}
+
+func (h *Handler) sendThread(w http.ResponseWriter, r *http.Request, id int) {
+	ctx := r.Context()
+
+	thread, err := h.threadRepo.GetThreadBySlugOrId(ctx, strconv.Itoa(id))
+	if err != nil || thread == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, thread).SendSuccess(w)
+}
+
+func (h *Handler) Move(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slugOrId := vars["slug_or_id"]
+	actor := r.URL.Query().Get("user")
+	move := new(models.MoveThreadRequest)
+	err := json.NewDecoder(r.Body).Decode(&move)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *move, "slug_or_id": slugOrId, "user": actor})
+
+	thread := h.approvedThread(w, r, slugOrId)
+	if thread == nil {
+		return
+	}
+
+	forum, err := h.forumRepo.GetForumBySlug(ctx, move.Forum)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forum == nil {
+		message := models.Message{
+			Message: "Can't find forum with id #" + move.Forum + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+	if forum.Archived {
+		message := models.Message{
+			Message: "Forum #" + forum.Slug + " is archived\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	if !forumModel.CheckModerator(w, r, h.forumRepo, thread.Forum, actor) || !forumModel.CheckModerator(w, r, h.forumRepo, forum.Slug, actor) {
+		return
+	}
+
+	if strings.EqualFold(thread.Forum, forum.Slug) {
+		response.New(http.StatusOK, thread).SendSuccess(w)
+		return
+	}
+
+	err = h.threadRepo.MoveThread(ctx, thread.Id, forum.Slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.sendThread(w, r, thread.Id)
+}
+
+func (h *Handler) Merge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	slugOrId := vars["slug_or_id"]
+	actor := r.URL.Query().Get("user")
+	merge := new(models.MergeThreadRequest)
+	err := json.NewDecoder(r.Body).Decode(&merge)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *merge, "slug_or_id": slugOrId, "user": actor})
+
+	target := h.approvedThread(w, r, slugOrId)
+	if target == nil {
+		return
+	}
+	source := h.approvedThread(w, r, merge.Thread)
+	if source == nil {
+		return
+	}
+	if target.Id == source.Id {
+		message := models.Message{
+			Message: "Can't merge thread into itself\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
+	forum, err := h.forumRepo.GetForumBySlug(ctx, target.Forum)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if forum != nil && forum.Archived {
+		message := models.Message{
+			Message: "Forum #" + forum.Slug + " is archived\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return
+	}
+
+	if !forumModel.CheckModerator(w, r, h.forumRepo, target.Forum, actor) || !forumModel.CheckModerator(w, r, h.forumRepo, source.Forum, actor) {
+		return
+	}
+
+	err = h.threadRepo.MergeThreads(ctx, target.Id, source.Id)
+	if err != nil {
+		if pqErr, ok := err.(pgx.PgError); ok && pqErr.Code == "12348" {
+			message := models.Message{
+				Message: "Thread #" + strconv.Itoa(target.Id) + " is full: " + pqErr.Message + "\n",
+			}
+			response.New(http.StatusConflict, message).SendSuccess(w)
+			return
+		}
+		if pqErr, ok := err.(pgx.PgError); ok && pqErr.Code == "12347" {
+			message := models.Message{
+				Message: "Can't merge thread #" + strconv.Itoa(source.Id) + " into #" + strconv.Itoa(target.Id) + ": " + pqErr.Message + "\n",
+			}
+			response.New(http.StatusConflict, message).SendSuccess(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.sendThread(w, r, target.Id)
+}
@@ -13,13 +13,30 @@ type ThreadHandler interface {
 	UpdateDetails(w http.ResponseWriter, r *http.Request)
 	GetPosts(w http.ResponseWriter, r *http.Request)
 	Vote(w http.ResponseWriter, r *http.Request)
+	Close(w http.ResponseWriter, r *http.Request)
+	GetTagThreads(w http.ResponseWriter, r *http.Request)
+	MarkRead(w http.ResponseWriter, r *http.Request)
+	Move(w http.ResponseWriter, r *http.Request)
+	Merge(w http.ResponseWriter, r *http.Request)
 }
 
 type ThreadRepo interface {
 	CreateThread(ctx context.Context, thread *models.Thread) (int, error)
-	UpdateThreadBySlug(ctx context.Context, thread *models.Thread) error
+	UpdateThread(ctx context.Context, thread *models.Thread) error
 	UpdateVote(ctx context.Context, vote *models.Vote) error
 	AddVote(ctx context.Context, vote *models.Vote) error
 	GetThreadBySlugOrId(ctx context.Context, slugOrId string) (*models.Thread, error)
-	GetPosts(ctx context.Context, threadPosts *models.ThreadPosts) (*[]models.Post, error)
+	GetThread(ctx context.Context, slugOrId string) (*models.Thread, error)
+	GetPosts(ctx context.Context, threadPosts *models.ThreadPosts, each func(post *models.Post) error) error
+	GetUserThreads(ctx context.Context, userThreads *models.UserThreads) (*[]models.Thread, error)
+	GetUserVotes(ctx context.Context, name string, limit int) (*[]models.Vote, error)
+	SetClosed(ctx context.Context, id int, closed bool) error
+	GetTagThreads(ctx context.Context, tagThreads *models.TagThreads) (*[]models.Thread, error)
+	MarkRead(ctx context.Context, nickname string, thread int, post int64) (*models.ThreadRead, error)
+	AddView(id int)
+	FlushViews(ctx context.Context) error
+	FlushViewsLoop(ctx context.Context)
+	MoveThread(ctx context.Context, id int, forum string) error
+	MergeThreads(ctx context.Context, targetId, sourceId int) error
+	SplitPosts(ctx context.Context, postId int64, thread *models.Thread) (int64, error)
 }
@@ -0,0 +1,386 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/logger"
+)
+
+// авторы одобренных постов и самой ветки становятся участниками форума
+const addForumUsersQuery = `
+	INSERT INTO forums_users (user_nickname, user_fullname, user_about, user_email, forum)
+	SELECT u.nickname, u.fullname, u.about, u.email, $2
+	FROM users as u
+	WHERE u.nickname IN (
+		SELECT p.user_create FROM posts as p WHERE p.thread = $1 AND p.status = 'approved'
+		UNION
+		SELECT th.user_create FROM threads as th WHERE th.id = $1 AND th.status = 'approved'
+	)
+	ON CONFLICT DO NOTHING
+`
+
+// из старого форума убираем тех авторов ветки, у кого там больше ничего не осталось
+const cleanupForumUsersQuery = `
+	DELETE FROM forums_users as fu
+	WHERE fu.forum = $2 AND fu.user_nickname IN (
+		SELECT p.user_create FROM posts as p WHERE p.thread = $1
+		UNION
+		SELECT th.user_create FROM threads as th WHERE th.id = $1
+	)
+	AND NOT EXISTS (
+		SELECT 1 FROM posts as p
+		WHERE p.forum = $2 AND p.user_create = fu.user_nickname AND p.status = 'approved'
+	)
+	AND NOT EXISTS (
+		SELECT 1 FROM threads as th
+		WHERE th.forum = $2 AND th.user_create = fu.user_nickname AND th.status = 'approved'
+	)
+`
+
+type txQuery struct {
+	query string
+	args  []interface{}
+}
+
+func execAll(tx *pgx.Tx, queries []txQuery) error {
+	for _, q := range queries {
+		_, err := tx.Exec(q.query, q.args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type lockedThread struct {
+	forum    string
+	approved bool
+	author   string
+	title    string
+	message  string
+	created  *time.Time
+}
+
+func lockThread(tx *pgx.Tx, id int) (*lockedThread, error) {
+	thread := new(lockedThread)
+	err := tx.QueryRow(
+		`
+		SELECT forum, status = 'approved', user_create, title, message, created
+		FROM threads WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(
+		&thread.forum,
+		&thread.approved,
+		&thread.author,
+		&thread.title,
+		&thread.message,
+		&thread.created,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return thread, nil
+}
+
+func approvedThreads(thread *lockedThread) int {
+	if thread.approved {
+		return 1
+	}
+	return 0
+}
+
+// approvedPosts возвращает число одобренных постов ветки и время последнего из них
+func approvedPosts(tx *pgx.Tx, thread int) (int, *time.Time, error) {
+	var count int
+	var last *time.Time
+	err := tx.QueryRow(
+		`
+		SELECT count(*), max(created) FROM posts
+		WHERE thread = $1 AND status = 'approved'
+	`, thread).Scan(&count, &last)
+
+	return count, last, err
+}
+
+func (r *repo) MoveThread(ctx context.Context, id int, forum string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		logger.Repo().AddFuncName("MoveThread").Error(ctx, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// новые посты ждут этой блокировки в триггере add_tree и берут форум уже после переноса
+	thread, err := lockThread(tx, id)
+	if err != nil {
+		logger.Repo().AddFuncName("MoveThread").Error(ctx, err)
+		return err
+	}
+
+	posts, _, err := approvedPosts(tx, id)
+	if err != nil {
+		logger.Repo().AddFuncName("MoveThread").Error(ctx, err)
+		return err
+	}
+
+	threads := approvedThreads(thread)
+
+	queries := []txQuery{
+		{`SELECT shift_forum_counters($1, $2, $3)`, []interface{}{thread.forum, -threads, -posts}},
+		{`SELECT shift_forum_counters($1, $2, $3)`, []interface{}{forum, threads, posts}},
+		{`UPDATE posts SET forum = $2 WHERE thread = $1`, []interface{}{id, forum}},
+		{`UPDATE threads SET forum = $2 WHERE id = $1`, []interface{}{id, forum}},
+		{`UPDATE reports SET forum = $2
+		WHERE thread = $1 OR post IN (SELECT p.id FROM posts as p WHERE p.thread = $1)`, []interface{}{id, forum}},
+		{addForumUsersQuery, []interface{}{id, forum}},
+		{cleanupForumUsersQuery, []interface{}{id, thread.forum}},
+	}
+
+	err = execAll(tx, queries)
+	if err != nil {
+		logger.Repo().AddFuncName("MoveThread").Error(ctx, err)
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Repo().AddFuncName("MoveThread").Error(ctx, err)
+		return err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"moved thread": id, "forum": forum})
+	return nil
+}
+
+func (r *repo) MergeThreads(ctx context.Context, targetId, sourceId int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		logger.Repo().AddFuncName("MergeThreads").Error(ctx, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// блокируем в порядке id, чтобы встречные слияния не взаимоблокировались
+	var target, source *lockedThread
+	if targetId < sourceId {
+		target, err = lockThread(tx, targetId)
+		if err == nil {
+			source, err = lockThread(tx, sourceId)
+		}
+	} else {
+		source, err = lockThread(tx, sourceId)
+		if err == nil {
+			target, err = lockThread(tx, targetId)
+		}
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("MergeThreads").Error(ctx, err)
+		return err
+	}
+
+	// обе ветки уже заблокированы, поэтому проверка не устареет до конца слияния
+	_, err = tx.Exec(`SELECT check_merge_limits($1, $2)`, targetId, sourceId)
+	if err != nil {
+		logger.Repo().AddFuncName("MergeThreads").Error(ctx, err)
+		return err
+	}
+
+	posts, lastPost, err := approvedPosts(tx, sourceId)
+	if err != nil {
+		logger.Repo().AddFuncName("MergeThreads").Error(ctx, err)
+		return err
+	}
+
+	// текст присоединяемой ветки становится корневым постом, под который уходят все её посты;
+	// счётчики ветки и форума за него увеличат триггеры. Пост остаётся за автором ветки,
+	// поэтому на время вставки check_user_active пропускает деактивированных
+	var opening int64
+	_, err = tx.Exec(`SELECT set_config('forums.system_post', 'on', true)`)
+	if err == nil {
+		err = tx.QueryRow(
+			`
+			INSERT INTO posts (title, user_create, message, forum, thread, created)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, source.title, source.author, source.message, target.forum, targetId, source.created).Scan(&opening)
+	}
+	if err == nil {
+		_, err = tx.Exec(`SELECT set_config('forums.system_post', 'off', true)`)
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("MergeThreads").Error(ctx, err)
+		return err
+	}
+
+	queries := []txQuery{
+		{`SELECT shift_forum_counters($1, $2, $3)`, []interface{}{source.forum, -approvedThreads(source), -posts}},
+		{`SELECT shift_forum_counters($1, 0, $2)`, []interface{}{target.forum, posts}},
+		{`UPDATE posts
+		SET thread = $2, forum = $3, parent = COALESCE(parent, $4),
+			tree = ARRAY[$4::integer] || tree, root_id = $4
+		WHERE thread = $1`, []interface{}{sourceId, targetId, target.forum, opening}},
+		{`UPDATE threads SET posts = posts + $2, last_post_at = greatest(last_post_at, $3)
+		WHERE id = $1`, []interface{}{targetId, posts, lastPost}},
+		// голоса переносим вставкой, чтобы сработал триггер insert_vote; повторные голоса теряются
+		{`INSERT INTO votes (user_create, thread, voice)
+		SELECT v.user_create, $2, v.voice
+		FROM votes as v JOIN users as u ON u.nickname = v.user_create
+		WHERE v.thread = $1 AND NOT u.deactivated
+		ON CONFLICT (user_create, thread) DO NOTHING`, []interface{}{sourceId, targetId}},
+		{`UPDATE reports SET forum = $2
+		WHERE post IN (SELECT p.id FROM posts as p WHERE p.thread = $1)`, []interface{}{targetId, target.forum}},
+		// жалобы и ссылки на исходную ветку переходят к целевой, иначе их удалит каскад
+		{`UPDATE reports SET thread = $2, forum = $3 WHERE thread = $1`, []interface{}{sourceId, targetId, target.forum}},
+		{`UPDATE threads_aliases SET thread = $2 WHERE thread = $1`, []interface{}{sourceId, targetId}},
+		{`INSERT INTO threads_aliases (old_slug, thread)
+		SELECT slug, $2 FROM threads WHERE id = $1 AND slug <> ''`, []interface{}{sourceId, targetId}},
+		{`DELETE FROM threads WHERE id = $1`, []interface{}{sourceId}},
+		{addForumUsersQuery, []interface{}{targetId, target.forum}},
+	}
+	if source.forum != target.forum {
+		queries = append(queries, txQuery{cleanupForumUsersQuery, []interface{}{targetId, source.forum}})
+	}
+
+	err = execAll(tx, queries)
+	if err != nil {
+		logger.Repo().AddFuncName("MergeThreads").Error(ctx, err)
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Repo().AddFuncName("MergeThreads").Error(ctx, err)
+		return err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"merged thread": sourceId, "into": targetId})
+	return nil
+}
+
+func (r *repo) SplitPosts(ctx context.Context, postId int64, thread *models.Thread) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		logger.Repo().AddFuncName("SplitPosts").Error(ctx, err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var origin int
+	var parent *int64
+	var depth int
+	var created *time.Time
+	err = tx.QueryRow(
+		`
+		SELECT thread, parent, array_length(tree, 1), created FROM posts WHERE id = $1
+	`, postId).Scan(&origin, &parent, &depth, &created)
+	if err != nil {
+		logger.Repo().AddFuncName("SplitPosts").Error(ctx, err)
+		return 0, err
+	}
+
+	source, err := lockThread(tx, origin)
+	if err != nil {
+		logger.Repo().AddFuncName("SplitPosts").Error(ctx, err)
+		return 0, err
+	}
+
+	// ветка создаётся одобренной, её счётчики увеличит триггер insert_thread
+	err = tx.QueryRow(
+		`
+		WITH dropped AS (
+			DELETE FROM threads_aliases WHERE old_slug = $5
+		)
+		INSERT INTO threads (title, user_create, message, forum, slug, created, last_post_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING id
+	`, thread.Title, thread.Author, thread.Message, thread.Forum, thread.Slug, thread.Created).Scan(&thread.Id)
+	if err != nil {
+		logger.Repo().AddFuncName("SplitPosts").Error(ctx, err)
+		return 0, err
+	}
+
+	// поддерево - все посты ветки, у которых postId есть в пути tree
+	var posts int
+	var lastPost *time.Time
+	err = tx.QueryRow(
+		`
+		SELECT count(*) FILTER (WHERE status = 'approved'), max(created) FILTER (WHERE status = 'approved')
+		FROM posts
+		WHERE thread = $1 AND tree @> ARRAY[$2::integer]
+	`, origin, postId).Scan(&posts, &lastPost)
+	if err != nil {
+		logger.Repo().AddFuncName("SplitPosts").Error(ctx, err)
+		return 0, err
+	}
+
+	queries := []txQuery{
+		{`UPDATE posts
+		SET thread = $3, forum = $4, tree = tree[$5:array_length(tree, 1)], root_id = $2,
+			parent = CASE WHEN id = $2 THEN NULL ELSE parent END
+		WHERE thread = $1 AND tree @> ARRAY[$2::integer]`, []interface{}{origin, postId, thread.Id, thread.Forum, depth}},
+		{`SELECT shift_forum_counters($1, 0, $2)`, []interface{}{source.forum, -posts}},
+		{`SELECT shift_forum_counters($1, 0, $2)`, []interface{}{thread.Forum, posts}},
+		{`UPDATE threads SET posts = posts - $2 WHERE id = $1`, []interface{}{origin, posts}},
+		{`UPDATE threads SET posts = posts + $2, last_post_at = greatest(last_post_at, $3)
+		WHERE id = $1`, []interface{}{thread.Id, posts, lastPost}},
+		{`UPDATE reports SET forum = $2
+		WHERE post IN (SELECT p.id FROM posts as p WHERE p.thread = $1)`, []interface{}{thread.Id, thread.Forum}},
+		{addForumUsersQuery, []interface{}{thread.Id, thread.Forum}},
+	}
+	if source.forum != thread.Forum {
+		queries = append(queries, txQuery{cleanupForumUsersQuery, []interface{}{thread.Id, source.forum}})
+	}
+
+	err = execAll(tx, queries)
+	if err != nil {
+		logger.Repo().AddFuncName("SplitPosts").Error(ctx, err)
+		return 0, err
+	}
+
+	// заглушка на месте перенесённого поста ведёт в новую ветку; время берёт у него,
+	// чтобы разделение не выглядело новой активностью в исходной ветке
+	var placeholder int64
+	err = tx.QueryRow(
+		`
+		INSERT INTO posts (parent, user_create, message, forum, thread, created)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, parent, thread.Author, "Moved to thread #"+strconv.Itoa(thread.Id), source.forum, origin, created).Scan(&placeholder)
+	if err != nil {
+		logger.Repo().AddFuncName("SplitPosts").Error(ctx, err)
+		return 0, err
+	}
+
+	// last_post_at исходной ветки мог прийти от перенесённых постов, пересчитываем по оставшимся
+	_, err = tx.Exec(
+		`
+		UPDATE threads as th
+		SET posts = p.posts, last_post_at = COALESCE(p.last, th.created)
+		FROM (
+			SELECT count(*) as posts, max(created) as last
+			FROM posts WHERE thread = $1 AND status = 'approved'
+		) as p
+		WHERE th.id = $1
+	`, origin)
+	if err != nil {
+		logger.Repo().AddFuncName("SplitPosts").Error(ctx, err)
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Repo().AddFuncName("SplitPosts").Error(ctx, err)
+		return 0, err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"split post": postId, "thread": thread.Id})
+	return placeholder, nil
+}
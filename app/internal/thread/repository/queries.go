@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"github.com/forums/app/models"
+	"github.com/forums/utils/query"
+)
+
+// Списочные запросы собираются отдельно от выполнения, чтобы их текст проверялся golden-тестами
+
+const selectPosts = `
+	SELECT p.id, p.parent, p.user_create, p.message,
+	p.is_edited, p.forum, p.thread, p.created, array_length(p.tree, 1) - 1
+	FROM posts as p
+`
+
+const selectThreads = `
+	SELECT th.id, th.title, th.user_create, th.forum,
+	th.message, th.slug, th.created, th.votes, th.closed,
+	th.tags, th.posts, th.last_post_at, th.views
+`
+
+func postsQuery(threadPosts *models.ThreadPosts) (string, []interface{}) {
+	q := query.New(selectPosts)
+	if threadPosts.Sort != "parent_tree" {
+		// в parent_tree тред задаёт подзапрос корней, root_id его не покидает
+		q.Where("p.thread = ?", threadPosts.ThreadId)
+	}
+	q.Where("p.status = 'approved'").
+		Desc(threadPosts.Desc)
+
+	switch threadPosts.Sort {
+	case "tree":
+		q.Since("p.tree", "(SELECT p2.tree FROM posts AS p2 WHERE p2.id = ?)", threadPosts.Since).
+			OrderBy("p.tree").
+			Limit(threadPosts.Limit)
+
+	case "parent_tree":
+		// limit считает корневые посты, поэтому страница корней выбирается подзапросом
+		roots := query.New("SELECT p2.id FROM posts AS p2").
+			Where("p2.thread = ?", threadPosts.ThreadId).
+			Where("p2.parent IS NULL").
+			Where("p2.status = 'approved'").
+			Desc(threadPosts.Desc).
+			Since("p2.root_id", "(SELECT p3.root_id FROM posts AS p3 WHERE p3.id = ?)", threadPosts.Since).
+			OrderBy("p2.id").
+			Limit(threadPosts.Limit)
+
+		q.WhereSub("p.root_id IN ?", roots)
+		if threadPosts.Desc {
+			q.OrderBy("p.root_id")
+		}
+		q.OrderAsc("p.tree")
+
+	default:
+		q.Since("p.id", "?", threadPosts.Since).
+			OrderBy("p.id").
+			Limit(threadPosts.Limit)
+	}
+
+	return q.Build()
+}
+
+func userThreadsQuery(userThreads *models.UserThreads) (string, []interface{}) {
+	q := query.New(selectThreads+", th.status = 'pending' FROM threads as th").
+		Where("th.user_create = ?", userThreads.Nickname)
+
+	if !userThreads.IncludeHidden {
+		q.Where("th.status = 'approved'")
+	}
+
+	if userThreads.Forum != "" {
+		q.Where("th.forum = ?", userThreads.Forum)
+	}
+
+	return q.Desc(userThreads.Desc).
+		SinceInclusive("th.created", "?", userThreads.Since).
+		OrderBy("th.created", "th.id").
+		Limit(userThreads.Limit).
+		Build()
+}
+
+func tagThreadsQuery(tagThreads *models.TagThreads) (string, []interface{}) {
+	return query.New(selectThreads+" FROM threads as th").
+		Where("th.tags @> ARRAY[?]::text[]", tagThreads.Tag).
+		Where("th.status = 'approved'").
+		Desc(tagThreads.Desc).
+		SinceInclusive("th.created", "?", tagThreads.Since).
+		OrderBy("th.created", "th.id").
+		Limit(tagThreads.Limit).
+		Build()
+}
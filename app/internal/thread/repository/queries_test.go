@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/query/querytest"
+)
+
+func TestPostsQuery(t *testing.T) {
+	axes := []querytest.Axis{
+		querytest.Strings("sort", "flat", "tree", "parent_tree"),
+		querytest.Desc(), querytest.Since("42"), querytest.Limit(),
+	}
+
+	querytest.Golden(t, "posts", querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+		return postsQuery(&models.ThreadPosts{
+			ThreadId: 7, Sort: p.String("sort"), Desc: p.Bool("desc"), Since: p.String("since"), Limit: p.Int("limit"),
+		})
+	}))
+}
+
+func TestUserThreadsQuery(t *testing.T) {
+	axes := []querytest.Axis{
+		{Name: "hidden", Values: []interface{}{false, true}},
+		querytest.Strings("forum", "", "golang"),
+		querytest.Desc(), querytest.Since("2020-01-01T00:00:00Z"), querytest.Limit(),
+	}
+
+	querytest.Golden(t, "user_threads", querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+		return userThreadsQuery(&models.UserThreads{
+			Nickname: "neo", IncludeHidden: p.Bool("hidden"), Forum: p.String("forum"),
+			Desc: p.Bool("desc"), Since: p.String("since"), Limit: p.Int("limit"),
+		})
+	}))
+}
+
+func TestTagThreadsQuery(t *testing.T) {
+	axes := []querytest.Axis{querytest.Desc(), querytest.Since("2020-01-01T00:00:00Z"), querytest.Limit()}
+
+	querytest.Golden(t, "tag_threads", querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+		return tagThreadsQuery(&models.TagThreads{
+			Tag: "go", Desc: p.Bool("desc"), Since: p.String("since"), Limit: p.Int("limit"),
+		})
+	}))
+}
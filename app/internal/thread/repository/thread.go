@@ -9,15 +9,18 @@ import (
 	threadModel "github.com/forums/app/internal/thread"
 	"github.com/forums/app/models"
 	"github.com/forums/utils/logger"
+	"github.com/forums/utils/prepared"
 )
 
 type repo struct {
-	DB *pgx.ConnPool
+	DB    *pgx.ConnPool
+	views *viewCounter
 }
 
 func NewThreadRepo(db *pgx.ConnPool) threadModel.ThreadRepo {
 	return &repo{
-		DB: db,
+		DB:    db,
+		views: newViewCounter(),
 	}
 }
 
@@ -25,25 +28,38 @@ func (r *repo) CreateThread(ctx context.Context, thread *models.Thread) (id int,
 	var query string
 	var queryParams []interface{}
 
+	status := models.StatusApproved
+	if thread.Pending {
+		status = models.StatusPending
+	}
+
 	queryParams = append(queryParams,
 		thread.Title,
 		thread.Author,
 		thread.Message,
 		thread.Forum,
 		thread.Slug,
+		status,
+		tagsParam(thread.Tags),
 	)
 	if thread.Created != nil {
 		query =
 			`
-		INSERT INTO threads (title, user_create, message, forum, slug, created)
-		VALUES ($1, $2, $3, $4, $5, $6) returning id
+		WITH dropped AS (
+			DELETE FROM threads_aliases WHERE old_slug = $5
+		)
+		INSERT INTO threads (title, user_create, message, forum, slug, status, tags, created, last_post_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8) returning id
 	`
 		queryParams = append(queryParams, thread.Created)
 	} else {
 		query =
 			`
-		INSERT INTO threads (title, user_create, message, forum, slug) 
-		VALUES ($1, $2, $3, $4, $5) returning id
+		WITH dropped AS (
+			DELETE FROM threads_aliases WHERE old_slug = $5
+		)
+		INSERT INTO threads (title, user_create, message, forum, slug, status, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) returning id
 	`
 	}
 
@@ -58,20 +74,53 @@ func (r *repo) CreateThread(ctx context.Context, thread *models.Thread) (id int,
 	return id, nil
 }
 
+const selectThread = `
+	SELECT th.id, th.title, th.user_create, th.forum,
+	th.message, th.slug, th.created, th.votes, th.closed,
+	th.tags, th.posts, th.last_post_at, th.views, th.status = 'pending'
+	FROM threads as th
+`
+
+var (
+	threadById   = prepared.Register("thread_by_id", selectThread+" WHERE th.id = $1 AND th.status <> 'rejected'")
+	threadBySlug = prepared.Register("thread_by_slug", selectThread+" WHERE th.slug = $1 AND th.status <> 'rejected'")
+)
+
+// GetThreadBySlugOrId - для чтения: slug ветки, слитой в другую, ведёт в ветку слияния
 func (r *repo) GetThreadBySlugOrId(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	thread, err := r.GetThread(ctx, slugOrId)
+	if err != nil || thread != nil {
+		return thread, err
+	}
+	if _, err := strconv.Atoi(slugOrId); err == nil {
+		return nil, nil
+	}
 
-	thread := new(models.Thread)
-	query :=
-		`
-		SELECT th.id, th.title, th.user_create, th.forum, 
-		th.message, th.slug, th.created, th.votes
-		FROM threads as th
-	`
+	var id int
+	err = r.DB.QueryRow(threadAlias, slugOrId).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("GetThreadBySlugOrId").Error(ctx, err)
+		return nil, err
+	}
+
+	return r.GetThread(ctx, strconv.Itoa(id))
+}
+
+var threadAlias = prepared.Register("thread_alias", `
+	SELECT ta.thread
+	FROM threads_aliases as ta
+	WHERE ta.old_slug = $1
+`)
 
+// GetThread ищет только среди настоящих веток, без алиасов
+func (r *repo) GetThread(ctx context.Context, slugOrId string) (*models.Thread, error) {
+	thread := new(models.Thread)
+	query := threadBySlug
 	if _, err := strconv.Atoi(slugOrId); err == nil {
-		query += " WHERE th.id = $1"
-	} else {
-		query += " WHERE th.slug = $1"
+		query = threadById
 	}
 
 	err := r.DB.QueryRow(query, slugOrId).Scan(
@@ -83,13 +132,19 @@ func (r *repo) GetThreadBySlugOrId(ctx context.Context, slugOrId string) (*model
 		&thread.Slug,
 		&thread.Created,
 		&thread.Votes,
+		&thread.Closed,
+		&thread.Tags,
+		&thread.Posts,
+		&thread.LastPostAt,
+		&thread.Views,
+		&thread.Pending,
 	)
 	if err == pgx.ErrNoRows {
 		logger.Repo().Info(ctx, logger.Fields{"thread": "not thread"})
 		return nil, nil
 	}
 	if err != nil {
-		logger.Repo().AddFuncName("GetThreadBySlugOrId").Error(ctx, err)
+		logger.Repo().AddFuncName("GetThread").Error(ctx, err)
 		return nil, err
 	}
 
@@ -97,16 +152,24 @@ func (r *repo) GetThreadBySlugOrId(ctx context.Context, slugOrId string) (*model
 	return thread, nil
 }
 
-func (r *repo) UpdateThreadBySlug(ctx context.Context, thread *models.Thread) error {
+// tagsParam не даёт записать NULL вместо пустого массива
+func tagsParam(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+func (r *repo) UpdateThread(ctx context.Context, thread *models.Thread) error {
 	query :=
 		`
-		UPDATE threads SET title = $1, message = $2
-		WHERE slug = $3
+		UPDATE threads SET title = $1, message = $2, tags = $3
+		WHERE id = $4
 	`
 
-	_, err := r.DB.Exec(query, thread.Title, thread.Message, thread.Slug)
+	_, err := r.DB.Exec(query, thread.Title, thread.Message, tagsParam(thread.Tags), thread.Id)
 	if err != nil {
-		logger.Repo().AddFuncName("UpdateThreadBySlug").Error(ctx, err)
+		logger.Repo().AddFuncName("UpdateThread").Error(ctx, err)
 		return err
 	}
 
@@ -147,257 +210,213 @@ func (r *repo) AddVote(ctx context.Context, vote *models.Vote) error {
 	return nil
 }
 
-func (r *repo) treeSort(ctx context.Context, threadPosts *models.ThreadPosts) (string, []interface{}) {
-	var queryParams []interface{}
-	query :=
-		`
-		SELECT p.id, p.parent, p.user_create, p.message,
-		p.is_edited, p.forum, p.thread, p.created
-		FROM posts as p
-		WHERE p.thread = $1
-	`
+// GetPosts отдаёт посты в each по мере чтения строк, не собирая страницу в памяти
+func (r *repo) GetPosts(ctx context.Context, threadPosts *models.ThreadPosts, each func(post *models.Post) error) error {
+	query, queryParams := postsQuery(threadPosts)
+
+	logger.Repo().Debug(ctx, logger.Fields{"query": query})
+
+	postsDB, err := r.DB.Query(query, queryParams...)
+	if err != nil {
+		logger.Repo().AddFuncName("GetPosts").Error(ctx, err)
+		return err
+	}
+	defer postsDB.Close()
 
-	queryParams = append(queryParams, threadPosts.ThreadId)
+	for postsDB.Next() {
+		post := new(models.Post)
+		err := postsDB.Scan(
+			&post.Id,
+			&post.Parent,
+			&post.Author,
+			&post.Message,
+			&post.IsEdited,
+			&post.Forum,
+			&post.Thread,
+			&post.Created,
+			&post.Depth,
+		)
 
-	if threadPosts.Desc {
-		if threadPosts.Since != "" {
-			query += " AND p.tree < (SELECT p2.tree from posts AS p2 WHERE p2.id = $2)"
-			queryParams = append(queryParams, threadPosts.Since)
+		if err != nil {
+			logger.Repo().AddFuncName("GetPosts").Error(ctx, err)
+			return err
 		}
 
-		query += " ORDER BY p.tree DESC"
-	} else {
-		if threadPosts.Since != "" {
-			query += " AND p.tree > (SELECT p2.tree from posts AS p2 WHERE p2.id = $2)"
-			queryParams = append(queryParams, threadPosts.Since)
+		if err := each(post); err != nil {
+			return err
 		}
-		query += " ORDER BY p.tree"
 	}
 
-	if threadPosts.Limit != "" {
-		query += " LIMIT " + threadPosts.Limit
+	if err := postsDB.Err(); err != nil {
+		logger.Repo().AddFuncName("GetPosts").Error(ctx, err)
+		return err
 	}
 
-	return query, queryParams
+	return nil
 }
 
-const selectParentTreeLimitAsc = `
-	SELECT p.id, p.parent, p.user_create, p.message,
-	p.is_edited, p.forum, p.thread, p.created
-	FROM posts as p
-	WHERE p.root_id IN (
-		SELECT p2.id
-		FROM posts p2
-		WHERE p2.thread = $1 AND p2.parent is NULL
-		ORDER BY p2.id
-		LIMIT $2
-	)
-	ORDER BY p.tree
-`
-
-const selectParentTreeLimitDesc = `
-	SELECT p.id, p.parent, p.user_create, p.message,
-	p.is_edited, p.forum, p.thread, p.created
-	FROM posts as p
-	WHERE p.root_id IN (
-		SELECT p2.id
-		FROM posts p2
-		WHERE p2.thread = $1 AND p2.parent is NULL
-		ORDER BY p2.id DESC
-		LIMIT $2
-	)
-	ORDER BY p.root_id DESC, p.tree ASC
-`
-
-const selectParentTreeSinceLimitAsc = `
-	SELECT p.id, p.parent, p.user_create, p.message,
-	p.is_edited, p.forum, p.thread, p.created
-	FROM posts as p
-	WHERE p.root_id IN (
-		SELECT p2.id
-		FROM posts p2
-		WHERE p2.thread = $1 AND p2.parent is NULL AND p2.root_id > (SELECT p3.root_id from posts p3 where p3.id = $2)
-		ORDER BY p2.id
-		LIMIT $3
-	)
-	ORDER BY p.tree
-`
-
-const selectParentTreeSinceLimitDesc = `
-	SELECT p.id, p.parent, p.user_create, p.message,
-	p.is_edited, p.forum, p.thread, p.created
-	FROM posts as p
-	WHERE p.root_id IN (
-		SELECT p2.id
-		FROM posts p2
-		WHERE p2.thread = $1 AND p2.parent is NULL AND p2.root_id < (SELECT p3.root_id from posts p3 where p3.id = $2)
-		ORDER BY p2.id DESC
-		LIMIT $3
-	)
-	ORDER BY p.root_id DESC, p.tree ASC
-`
+func (r *repo) GetUserThreads(ctx context.Context, userThreads *models.UserThreads) (*[]models.Thread, error) {
+	query, queryParams := userThreadsQuery(userThreads)
 
-const selectParentTreeAsc = `
-	SELECT p.id, p.parent, p.user_create, p.message,
-	p.is_edited, p.forum, p.thread, p.created
-	FROM posts as p
-	WHERE p.thread = $1
-	ORDER BY p.tree
-`
-
-const selectParentTreeDesc = `
-	SELECT p.id, p.parent, p.user_create, p.message,
-	p.is_edited, p.forum, p.thread, p.created
-	FROM posts as p
-	WHERE p.thread = $1
-	ORDER BY p.root_id DESC, p.tree ASC
-`
-
-const selectParentTreeSinceAsc = `
-	SELECT p.id, p.parent, p.user_create, p.message,
-	p.is_edited, p.forum, p.thread, p.created
-	FROM posts as p
-	WHERE p.thread = $1 and p.root_id > (SELECT p3.root_id from posts p3 where p3.id = $2)
-	ORDER BY p.tree
-`
+	logger.Repo().AddFuncName("GetUserThreads").Debug(ctx, logger.Fields{"query": query})
 
-const selectParentTreeSinceDesc = `
-	SELECT p.id, p.parent, p.user_create, p.message,
-	p.is_edited, p.forum, p.thread, p.created
-	FROM posts as p
-	WHERE p.thread = $1 and p.root_id < (SELECT p3.root_id from posts p3 where p3.id = $2)
-	ORDER BY p.root_id DESC, p.tree ASC
-`
+	threadsDB, err := r.DB.Query(query, queryParams...)
+	if err != nil {
+		logger.Repo().AddFuncName("GetUserThreads").Error(ctx, err)
+		return nil, err
+	}
+	defer threadsDB.Close()
 
-func (r *repo) parentTreeSort(ctx context.Context, threadPosts *models.ThreadPosts) (string, []interface{}) {
-	var queryParams []interface{}
-	var query string
+	threads := make([]models.Thread, 0)
+	for threadsDB.Next() {
+		thread := new(models.Thread)
+		err := threadsDB.Scan(
+			&thread.Id,
+			&thread.Title,
+			&thread.Author,
+			&thread.Forum,
+			&thread.Message,
+			&thread.Slug,
+			&thread.Created,
+			&thread.Votes,
+			&thread.Closed,
+			&thread.Tags,
+			&thread.Posts,
+			&thread.LastPostAt,
+			&thread.Views,
+			&thread.Pending,
+		)
 
-	queryParams = append(queryParams, threadPosts.ThreadId)
-
-	switch threadPosts.Limit {
-	case "":
-		if threadPosts.Desc {
-			if threadPosts.Since != "" {
-				query = selectParentTreeSinceDesc
-				queryParams = append(queryParams, threadPosts.Since)
-			} else {
-				query = selectParentTreeDesc
-			}
-		} else {
-			if threadPosts.Since != "" {
-				query = selectParentTreeSinceAsc
-				queryParams = append(queryParams, threadPosts.Since)
-			} else {
-				query = selectParentTreeAsc
-			}
-		}
-	default:
-		if threadPosts.Desc {
-			if threadPosts.Since != "" {
-				query = selectParentTreeSinceLimitDesc
-				queryParams = append(queryParams, threadPosts.Since)
-			} else {
-				query = selectParentTreeLimitDesc
-			}
-		} else {
-			if threadPosts.Since != "" {
-				query = selectParentTreeSinceLimitAsc
-				queryParams = append(queryParams, threadPosts.Since)
-			} else {
-				query = selectParentTreeLimitAsc
-			}
+		if err != nil {
+			logger.Repo().AddFuncName("GetUserThreads").Error(ctx, err)
+			return nil, err
 		}
-		queryParams = append(queryParams, threadPosts.Limit)
+
+		threads = append(threads, *thread)
 	}
 
-	return query, queryParams
+	return &threads, nil
 }
 
-func (r *repo) flatSort(ctx context.Context, threadPosts *models.ThreadPosts) (string, []interface{}) {
-	var queryParams []interface{}
+func (r *repo) GetUserVotes(ctx context.Context, name string, limit int) (*[]models.Vote, error) {
 	query :=
 		`
-		SELECT p.id, p.parent, p.user_create, p.message,
-		p.is_edited, p.forum, p.thread, p.created
-		FROM posts as p
-		WHERE p.thread = $1
+		SELECT v.id, v.user_create, v.thread, v.voice
+		FROM votes as v
+		WHERE v.user_create = $1
+		ORDER BY v.id
+		LIMIT $2
 	`
 
-	queryParams = append(queryParams, threadPosts.ThreadId)
-
-	if threadPosts.Desc {
-		if threadPosts.Since != "" {
-			query += " AND p.id < $2"
-			queryParams = append(queryParams, threadPosts.Since)
-		}
+	votesDB, err := r.DB.Query(query, name, limit)
+	if err != nil {
+		logger.Repo().AddFuncName("GetUserVotes").Error(ctx, err)
+		return nil, err
+	}
+	defer votesDB.Close()
+
+	votes := make([]models.Vote, 0)
+	for votesDB.Next() {
+		vote := new(models.Vote)
+		err := votesDB.Scan(
+			&vote.Id,
+			&vote.User,
+			&vote.Thread,
+			&vote.Voice,
+		)
 
-		query += " ORDER BY p.id DESC"
-	} else {
-		if threadPosts.Since != "" {
-			query += " AND p.id > $2"
-			queryParams = append(queryParams, threadPosts.Since)
+		if err != nil {
+			logger.Repo().AddFuncName("GetUserVotes").Error(ctx, err)
+			return nil, err
 		}
-		query += " ORDER BY p.id"
-	}
 
-	if threadPosts.Limit != "" {
-		query += " LIMIT " + threadPosts.Limit
+		votes = append(votes, *vote)
 	}
 
-	return query, queryParams
+	return &votes, nil
 }
 
-func (r *repo) GetPosts(ctx context.Context, threadPosts *models.ThreadPosts) (*[]models.Post, error) {
-	// TODO: подумать как здесь можно сделать покрасивее
-	queryParams := make([]interface{}, 0)
-	var query string
-
-	queryParams = append(queryParams, threadPosts.ThreadId)
+func (r *repo) SetClosed(ctx context.Context, id int, closed bool) error {
+	query :=
+		`
+		UPDATE threads SET closed = $1
+		WHERE id = $2
+	`
 
-	if threadPosts.Sort == "" {
-		threadPosts.Sort = "flat"
+	_, err := r.DB.Exec(query, closed, id)
+	if err != nil {
+		logger.Repo().AddFuncName("SetClosed").Error(ctx, err)
+		return err
 	}
-	switch threadPosts.Sort {
-	case "tree":
-		query, queryParams = r.treeSort(ctx, threadPosts)
 
-	case "parent_tree":
-		query, queryParams = r.parentTreeSort(ctx, threadPosts)
+	return nil
+}
 
-	case "flat":
-		query, queryParams = r.flatSort(ctx, threadPosts)
-	}
+func (r *repo) GetTagThreads(ctx context.Context, tagThreads *models.TagThreads) (*[]models.Thread, error) {
+	query, queryParams := tagThreadsQuery(tagThreads)
 
-	logger.Repo().Debug(ctx, logger.Fields{"query": query})
+	logger.Repo().AddFuncName("GetTagThreads").Debug(ctx, logger.Fields{"query": query})
 
 	threadsDB, err := r.DB.Query(query, queryParams...)
 	if err != nil {
-		logger.Repo().AddFuncName("GetPosts").Error(ctx, err)
+		logger.Repo().AddFuncName("GetTagThreads").Error(ctx, err)
 		return nil, err
 	}
+	defer threadsDB.Close()
 
-	posts := make([]models.Post, 0)
+	threads := make([]models.Thread, 0)
 	for threadsDB.Next() {
-		post := new(models.Post)
+		thread := new(models.Thread)
 		err := threadsDB.Scan(
-			&post.Id,
-			&post.Parent,
-			&post.Author,
-			&post.Message,
-			&post.IsEdited,
-			&post.Forum,
-			&post.Thread,
-			&post.Created,
+			&thread.Id,
+			&thread.Title,
+			&thread.Author,
+			&thread.Forum,
+			&thread.Message,
+			&thread.Slug,
+			&thread.Created,
+			&thread.Votes,
+			&thread.Closed,
+			&thread.Tags,
+			&thread.Posts,
+			&thread.LastPostAt,
+			&thread.Views,
 		)
 
 		if err != nil {
-			logger.Repo().AddFuncName("GetPosts").Error(ctx, err)
+			logger.Repo().AddFuncName("GetTagThreads").Error(ctx, err)
 			return nil, err
 		}
 
-		posts = append(posts, *post)
+		threads = append(threads, *thread)
+	}
+
+	return &threads, nil
+}
+
+func (r *repo) MarkRead(ctx context.Context, nickname string, thread int, post int64) (*models.ThreadRead, error) {
+	// post = 0 - прочитано всё, что есть в ветке сейчас
+	query :=
+		`
+		INSERT INTO threads_reads (user_nickname, thread, last_read)
+		VALUES ($1, $2, CASE WHEN $3 = 0
+			THEN (SELECT COALESCE(max(p.id), 0) FROM posts as p WHERE p.thread = $2)
+			ELSE $3 END)
+		ON CONFLICT (user_nickname, thread) DO UPDATE
+		SET last_read = greatest(threads_reads.last_read, EXCLUDED.last_read)
+		RETURNING user_nickname, thread, last_read
+	`
+
+	read := new(models.ThreadRead)
+	err := r.DB.QueryRow(query, nickname, thread, post).Scan(
+		&read.Nickname,
+		&read.Thread,
+		&read.LastRead,
+	)
+	if err != nil {
+		logger.Repo().AddFuncName("MarkRead").Error(ctx, err)
+		return nil, err
 	}
 
-	return &posts, nil
+	return read, nil
 }
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/forums/app/config"
+	"github.com/forums/utils/logger"
+)
+
+// viewCounter копит просмотры веток в памяти, чтобы популярная ветка
+// не обновлялась на каждый запрос
+type viewCounter struct {
+	mu      sync.Mutex
+	pending map[int]int32
+}
+
+func newViewCounter() *viewCounter {
+	return &viewCounter{
+		pending: make(map[int]int32),
+	}
+}
+
+func (v *viewCounter) add(id int, count int32) {
+	v.mu.Lock()
+	v.pending[id] += count
+	v.mu.Unlock()
+}
+
+func (v *viewCounter) take() map[int]int32 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	pending := v.pending
+	v.pending = make(map[int]int32, len(pending))
+	return pending
+}
+
+func (r *repo) AddView(id int) {
+	r.views.add(id, 1)
+}
+
+func (r *repo) FlushViews(ctx context.Context) error {
+	pending := r.views.take()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ids := make([]int32, 0, len(pending))
+	counts := make([]int32, 0, len(pending))
+	for id, count := range pending {
+		ids = append(ids, int32(id))
+		counts = append(counts, count)
+	}
+
+	query :=
+		`
+		UPDATE threads SET views = views + v.count
+		FROM unnest($1::integer[], $2::integer[]) as v(id, count)
+		WHERE threads.id = v.id
+	`
+
+	_, err := r.DB.Exec(query, ids, counts)
+	if err != nil {
+		logger.Repo().AddFuncName("FlushViews").Error(ctx, err)
+
+		// не теряем просмотры, попробуем записать со следующей пачкой
+		for id, count := range pending {
+			r.views.add(id, count)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// FlushViewsLoop пишет просмотры раз в ViewsFlushInterval, а после отмены ctx -
+// последний раз, чтобы накопленное не потерялось при остановке сервера
+func (r *repo) FlushViewsLoop(ctx context.Context) {
+	ticker := time.NewTicker(config.ViewsFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = r.FlushViews(ctx)
+		case <-ctx.Done():
+			_ = r.FlushViews(context.Background())
+			return
+		}
+	}
+}
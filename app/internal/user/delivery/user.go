@@ -1,23 +1,42 @@
 package delivery
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/forums/app/config"
+	forumModel "github.com/forums/app/internal/forum"
+	postModel "github.com/forums/app/internal/post"
+	threadModel "github.com/forums/app/internal/thread"
 	userModel "github.com/forums/app/internal/user"
 	"github.com/forums/app/models"
+	"github.com/forums/utils/errors"
 	"github.com/forums/utils/logger"
+	"github.com/forums/utils/pagination"
 	"github.com/forums/utils/response"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx"
 )
 
 type handler struct {
-	userRepo userModel.UserRepo
+	userRepo   userModel.UserRepo
+	postRepo   postModel.PostRepo
+	threadRepo threadModel.ThreadRepo
+	forumRepo  forumModel.ForumRepo
 }
 
-func NewUserHandler(userRepo userModel.UserRepo) userModel.UserHandler {
+func NewUserHandler(userRepo userModel.UserRepo, postRepo postModel.PostRepo,
+	threadRepo threadModel.ThreadRepo, forumRepo forumModel.ForumRepo) userModel.UserHandler {
 	return &handler{
-		userRepo: userRepo,
+		userRepo:   userRepo,
+		postRepo:   postRepo,
+		threadRepo: threadRepo,
+		forumRepo:  forumRepo,
 	}
 }
 
@@ -68,7 +87,17 @@ func (h *handler) GetUser(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if user == nil {
+	if user == nil || user.Deactivated {
+		alias, err := h.userRepo.GetUserAlias(ctx, nickname)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if alias != "" {
+			http.Redirect(w, r, "/api/user/"+url.PathEscape(alias)+"/profile", http.StatusMovedPermanently)
+			return
+		}
+
 		message := models.Message{
 			Message: "Can't find user with id #" + nickname + "\n",
 		}
@@ -116,7 +145,7 @@ func (h *handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if userDb == nil {
+	if userDb == nil || userDb.Deactivated {
 		message := models.Message{
 			Message: "Can't find user with id #" + newUser.Nickname + "\n",
 		}
@@ -144,3 +173,370 @@ func (h *handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	response.New(http.StatusOK, newUser).SendSuccess(w)
 }
+
+func (h *handler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	usersList := new(models.UsersList)
+	usersList.Query = r.URL.Query().Get("query")
+	usersList.Match = r.URL.Query().Get("match")
+	page, pageErr := pagination.Parse(r.URL.Query(), config.UsersPage, pagination.SinceString)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+	usersList.Limit = page.Limit
+	usersList.Since = page.Since
+	usersList.Desc = page.Desc
+
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *usersList})
+
+	users, err := h.userRepo.GetUsers(ctx, usersList)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, users).SendSuccess(w)
+}
+
+func (h *handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	nickname := vars["nickname"]
+	logger.Delivery().Info(ctx, logger.Fields{"request data": nickname})
+
+	stats, err := h.userRepo.GetUserStats(ctx, nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if stats == nil {
+		message := models.Message{
+			Message: "Can't find user with id #" + nickname + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	response.New(http.StatusOK, stats).SendSuccess(w)
+}
+
+func (h *handler) GetPosts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	userPosts := new(models.UserPosts)
+	userPosts.Nickname = vars["nickname"]
+	userPosts.Forum = r.URL.Query().Get("forum")
+	page, pageErr := pagination.Parse(r.URL.Query(), config.UserPostsPage, pagination.SinceId)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+	userPosts.Limit = page.Limit
+	userPosts.Since = page.Since
+	userPosts.Desc = page.Desc
+
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *userPosts})
+
+	user, err := h.userRepo.GetUserByName(ctx, userPosts.Nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		message := models.Message{
+			Message: "Can't find user with id #" + userPosts.Nickname + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	userPosts.Nickname = user.Nickname
+	posts, err := h.postRepo.GetUserPosts(ctx, userPosts)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, posts).SendSuccess(w)
+}
+
+func (h *handler) GetThreads(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	userThreads := new(models.UserThreads)
+	userThreads.Nickname = vars["nickname"]
+	userThreads.Forum = r.URL.Query().Get("forum")
+	page, pageErr := pagination.Parse(r.URL.Query(), config.UserThreadsPage, pagination.SinceTime)
+	if pageErr != nil {
+		response.New(pageErr.Code(), models.Message{Message: pageErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+	userThreads.Limit = page.Limit
+	userThreads.Since = page.Since
+	userThreads.Desc = page.Desc
+
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *userThreads})
+
+	user, err := h.userRepo.GetUserByName(ctx, userThreads.Nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		message := models.Message{
+			Message: "Can't find user with id #" + userThreads.Nickname + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	userThreads.Nickname = user.Nickname
+	threads, err := h.threadRepo.GetUserThreads(ctx, userThreads)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, threads).SendSuccess(w)
+}
+
+// checkSelf пускает только владельца аккаунта: user из запроса должен совпасть с nickname.
+// При отказе пишет 403.
+func checkSelf(w http.ResponseWriter, r *http.Request, nickname, action string) bool {
+	if !strings.EqualFold(r.URL.Query().Get("user"), nickname) {
+		message := models.Message{
+			Message: "Only user #" + nickname + " can " + action + " this account\n",
+		}
+		response.New(http.StatusForbidden, message).SendSuccess(w)
+		return false
+	}
+
+	return true
+}
+
+func (h *handler) RenameUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	nickname := vars["nickname"]
+	rename := new(models.RenameUserRequest)
+	err := json.NewDecoder(r.Body).Decode(&rename)
+	if err != nil {
+		sendErr := errors.New(http.StatusBadRequest, err.Error())
+		logger.Delivery().Error(ctx, sendErr)
+		w.WriteHeader(sendErr.Code())
+		return
+	}
+	defer r.Body.Close()
+	logger.Delivery().Info(ctx, logger.Fields{"request data": *rename, "nickname": nickname})
+
+	if rename.Nickname == "" {
+		message := models.Message{
+			Message: "New nickname is empty\n",
+		}
+		response.New(http.StatusBadRequest, message).SendSuccess(w)
+		return
+	}
+
+	user, err := h.userRepo.GetUserByName(ctx, nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		message := models.Message{
+			Message: "Can't find user with id #" + nickname + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	if !checkSelf(w, r, user.Nickname, "rename") {
+		return
+	}
+
+	userDb, err := h.userRepo.GetUserByName(ctx, rename.Nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if userDb != nil && userDb.Nickname != user.Nickname {
+		response.New(http.StatusConflict, userDb).SendSuccess(w)
+		return
+	}
+
+	err = h.userRepo.RenameUser(ctx, user.Nickname, rename.Nickname, config.NicknameAliasTTL)
+	if err != nil {
+		if pqErr, ok := err.(pgx.PgError); ok && pqErr.Code == pgerrcode.UniqueViolation {
+			message := models.Message{
+				Message: "Nickname #" + rename.Nickname + " is already taken\n",
+			}
+			response.New(http.StatusConflict, message).SendSuccess(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	user.Nickname = rename.Nickname
+	response.New(http.StatusOK, user).SendSuccess(w)
+}
+
+func (h *handler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	nickname := vars["nickname"]
+	logger.Delivery().Info(ctx, logger.Fields{"request data": nickname})
+
+	user, err := h.userRepo.GetUserByName(ctx, nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		message := models.Message{
+			Message: "Can't find user with id #" + nickname + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	if !checkSelf(w, r, user.Nickname, "deactivate") {
+		return
+	}
+
+	err = h.userRepo.DeactivateUser(ctx, user.Nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	user.Deactivated = true
+	response.New(http.StatusOK, user).SendSuccess(w)
+}
+
+func (h *handler) newErasedNickname() (string, error) {
+	suffix := make([]byte, 8)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+
+	return "erased_" + hex.EncodeToString(suffix), nil
+}
+
+func (h *handler) EraseUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	nickname := vars["nickname"]
+	logger.Delivery().Info(ctx, logger.Fields{"request data": nickname})
+
+	user, err := h.userRepo.GetUserByName(ctx, nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		message := models.Message{
+			Message: "Can't find user with id #" + nickname + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	if !checkSelf(w, r, user.Nickname, "erase") {
+		return
+	}
+
+	erasedName, err := h.newErasedNickname()
+	if err != nil {
+		logger.Delivery().AddFuncName("EraseUser").Error(ctx, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = h.userRepo.EraseUser(ctx, user.Nickname, erasedName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// отдаём то, что осталось от профиля после стирания
+	user, err = h.userRepo.GetUserByName(ctx, erasedName)
+	if err != nil || user == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, user).SendSuccess(w)
+}
+
+func (h *handler) ExportUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	nickname := vars["nickname"]
+	logger.Delivery().Info(ctx, logger.Fields{"request data": nickname})
+
+	// limit ограничивает ветки, посты и голоса по отдельности, остальное - через ленты пользователя
+	limit, limitErr := pagination.ParseLimit(r.URL.Query(), config.UserExportPage)
+	if limitErr != nil {
+		response.New(limitErr.Code(), models.Message{Message: limitErr.Error() + "\n"}).SendSuccess(w)
+		return
+	}
+
+	user, err := h.userRepo.GetUserByName(ctx, nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		message := models.Message{
+			Message: "Can't find user with id #" + nickname + "\n",
+		}
+		response.New(http.StatusNotFound, message).SendSuccess(w)
+		return
+	}
+
+	if !checkSelf(w, r, user.Nickname, "export") {
+		return
+	}
+
+	export := models.UserExport{
+		User: user,
+	}
+
+	export.Forums, err = h.forumRepo.GetUserForums(ctx, user.Nickname)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	export.Threads, err = h.threadRepo.GetUserThreads(ctx, &models.UserThreads{Nickname: user.Nickname, IncludeHidden: true, Limit: limit})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	export.Posts, err = h.postRepo.GetUserPosts(ctx, &models.UserPosts{Nickname: user.Nickname, IncludeHidden: true, Limit: limit})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	export.Votes, err = h.threadRepo.GetUserVotes(ctx, user.Nickname, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.New(http.StatusOK, export).SendSuccess(w)
+}
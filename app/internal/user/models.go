@@ -3,6 +3,7 @@ package user
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/forums/app/models"
 )
@@ -11,6 +12,14 @@ type UserHandler interface {
 	CreateUser(w http.ResponseWriter, r *http.Request)
 	GetUser(w http.ResponseWriter, r *http.Request)
 	UpdateUser(w http.ResponseWriter, r *http.Request)
+	GetUsers(w http.ResponseWriter, r *http.Request)
+	GetStats(w http.ResponseWriter, r *http.Request)
+	GetPosts(w http.ResponseWriter, r *http.Request)
+	GetThreads(w http.ResponseWriter, r *http.Request)
+	RenameUser(w http.ResponseWriter, r *http.Request)
+	DeactivateUser(w http.ResponseWriter, r *http.Request)
+	EraseUser(w http.ResponseWriter, r *http.Request)
+	ExportUser(w http.ResponseWriter, r *http.Request)
 }
 
 type UserRepo interface {
@@ -19,4 +28,10 @@ type UserRepo interface {
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error) // TODO: возможно удлаить метод
 	UpdateUser(ctx context.Context, user *models.User) (id int, err error)
 	GetUserByNameAndEmail(ctx context.Context, name, email string) (*[]models.User, error)
+	GetUsers(ctx context.Context, usersList *models.UsersList) (*[]models.User, error)
+	GetUserStats(ctx context.Context, name string) (*models.UserStats, error)
+	RenameUser(ctx context.Context, oldName, newName string, aliasTTL time.Duration) error
+	GetUserAlias(ctx context.Context, oldName string) (string, error)
+	DeactivateUser(ctx context.Context, name string) error
+	EraseUser(ctx context.Context, name, erasedName string) error
 }
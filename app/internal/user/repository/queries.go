@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"github.com/forums/app/models"
+	"github.com/forums/utils/query"
+)
+
+// Списочные запросы собираются отдельно от выполнения, чтобы их текст проверялся golden-тестами
+
+func usersQuery(usersList *models.UsersList) (string, []interface{}) {
+	q := query.New("SELECT nickname, fullname, about, email FROM users").
+		Where("NOT deactivated")
+
+	if usersList.Query != "" {
+		pattern := escapeLike(usersList.Query) + "%"
+		if usersList.Match == "substring" {
+			pattern = "%" + pattern
+		}
+
+		q.Where("(nickname ILIKE ? OR fullname ILIKE ?)", pattern, pattern)
+	}
+
+	return q.Desc(usersList.Desc).
+		Since("nickname", "?", usersList.Since).
+		OrderBy("nickname").
+		Limit(usersList.Limit).
+		Build()
+}
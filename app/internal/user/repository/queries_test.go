@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/forums/app/models"
+	"github.com/forums/utils/query/querytest"
+)
+
+func TestUsersQuery(t *testing.T) {
+	axes := []querytest.Axis{
+		querytest.Strings("query", "", "ne_o"),
+		querytest.Strings("match", "", "substring"),
+		querytest.Desc(), querytest.Since("neo"), querytest.Limit(),
+	}
+
+	querytest.Golden(t, "users", querytest.Matrix(axes, func(p querytest.Params) (string, []interface{}) {
+		return usersQuery(&models.UsersList{
+			Query: p.String("query"), Match: p.String("match"),
+			Desc: p.Bool("desc"), Since: p.String("since"), Limit: p.Int("limit"),
+		})
+	}))
+}
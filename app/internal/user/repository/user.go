@@ -2,10 +2,13 @@ package repository
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	userModel "github.com/forums/app/internal/user"
 	"github.com/forums/app/models"
 	"github.com/forums/utils/logger"
+	"github.com/forums/utils/prepared"
 	"github.com/jackc/pgx"
 )
 
@@ -60,20 +63,21 @@ func (r *repo) GetUserByNameAndEmail(ctx context.Context, name, email string) (*
 	return &users, nil
 }
 
+var userByName = prepared.Register("user_by_name", `
+	SELECT nickname, fullname, about, email, deactivated
+	FROM users WHERE nickname = $1
+`)
+
 func (r *repo) GetUserByName(ctx context.Context, name string) (*models.User, error) {
 
 	logger.Repo().Debug(ctx, logger.Fields{"name": name})
 	user := new(models.User)
-	query :=
-		`
-		SELECT nickname, fullname, about, email
-		FROM users WHERE nickname = $1
-	`
-	err := r.DB.QueryRow(query, name).Scan(
+	err := r.DB.QueryRow(userByName, name).Scan(
 		&user.Nickname,
 		&user.Fullname,
 		&user.About,
 		&user.Email,
+		&user.Deactivated,
 	)
 	if err == pgx.ErrNoRows {
 		logger.Repo().Info(ctx, logger.Fields{"user": "not user with nickname"})
@@ -156,3 +160,224 @@ func (r *repo) UpdateUser(ctx context.Context, user *models.User) (id int, err e
 	logger.Repo().Debug(ctx, logger.Fields{"user id": id})
 	return id, nil
 }
+
+func escapeLike(pattern string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(pattern)
+}
+
+func (r *repo) GetUsers(ctx context.Context, usersList *models.UsersList) (*[]models.User, error) {
+	query, queryParams := usersQuery(usersList)
+
+	logger.Repo().AddFuncName("GetUsers").Debug(ctx, logger.Fields{"query": query})
+
+	usersDB, err := r.DB.Query(query, queryParams...)
+	if err != nil {
+		logger.Repo().AddFuncName("GetUsers").Error(ctx, err)
+		return nil, err
+	}
+	defer usersDB.Close()
+
+	users := make([]models.User, 0)
+	for usersDB.Next() {
+		user := new(models.User)
+
+		err := usersDB.Scan(
+			&user.Nickname,
+			&user.Fullname,
+			&user.About,
+			&user.Email,
+		)
+
+		if err != nil {
+			logger.Repo().AddFuncName("GetUsers").Error(ctx, err)
+			return nil, err
+		}
+
+		users = append(users, *user)
+	}
+
+	logger.Repo().Info(ctx, logger.Fields{"users": users})
+	return &users, nil
+}
+
+func (r *repo) GetUserStats(ctx context.Context, name string) (*models.UserStats, error) {
+	stats := new(models.UserStats)
+	query :=
+		`
+		SELECT u.nickname,
+		(SELECT COUNT(*) FROM posts AS p WHERE p.user_create = u.nickname),
+		(SELECT COUNT(*) FROM threads AS th WHERE th.user_create = u.nickname),
+		(SELECT COUNT(*) FROM votes AS v WHERE v.user_create = u.nickname),
+		(SELECT COUNT(*) FROM forums_users AS fu WHERE fu.user_nickname = u.nickname),
+		LEAST(
+			(SELECT MIN(p.created) FROM posts AS p WHERE p.user_create = u.nickname),
+			(SELECT MIN(th.created) FROM threads AS th WHERE th.user_create = u.nickname)
+		),
+		GREATEST(
+			(SELECT MAX(p.created) FROM posts AS p WHERE p.user_create = u.nickname),
+			(SELECT MAX(th.created) FROM threads AS th WHERE th.user_create = u.nickname)
+		)
+		FROM users AS u
+		WHERE u.nickname = $1
+	`
+
+	err := r.DB.QueryRow(query, name).Scan(
+		&stats.Nickname,
+		&stats.Posts,
+		&stats.Threads,
+		&stats.Votes,
+		&stats.Forums,
+		&stats.FirstActivity,
+		&stats.LastActivity,
+	)
+	if err == pgx.ErrNoRows {
+		logger.Repo().Info(ctx, logger.Fields{"user": "not user with nickname"})
+		return nil, nil
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("GetUserStats").Error(ctx, err)
+		return nil, err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"user stats": *stats})
+	return stats, nil
+}
+
+func (r *repo) RenameUser(ctx context.Context, oldName, newName string, aliasTTL time.Duration) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		logger.Repo().AddFuncName("RenameUser").Error(ctx, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// ссылки в forums, threads, posts, votes и forums_users обновятся через ON UPDATE CASCADE
+	query :=
+		`
+		UPDATE users SET nickname = $1
+		WHERE nickname = $2
+	`
+	_, err = tx.Exec(query, newName, oldName)
+	if err != nil {
+		logger.Repo().AddFuncName("RenameUser").Error(ctx, err)
+		return err
+	}
+
+	query =
+		`
+		DELETE FROM users_aliases
+		WHERE old_nickname = $1 OR expires < now()
+	`
+	_, err = tx.Exec(query, newName)
+	if err != nil {
+		logger.Repo().AddFuncName("RenameUser").Error(ctx, err)
+		return err
+	}
+
+	if !strings.EqualFold(oldName, newName) {
+		query =
+			`
+			INSERT INTO users_aliases (old_nickname, nickname, expires)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (old_nickname) DO UPDATE
+			SET nickname = EXCLUDED.nickname, expires = EXCLUDED.expires
+		`
+		_, err = tx.Exec(query, oldName, newName, time.Now().Add(aliasTTL))
+		if err != nil {
+			logger.Repo().AddFuncName("RenameUser").Error(ctx, err)
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Repo().AddFuncName("RenameUser").Error(ctx, err)
+		return err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"old nickname": oldName, "new nickname": newName})
+	return nil
+}
+
+func (r *repo) GetUserAlias(ctx context.Context, oldName string) (string, error) {
+	query :=
+		`
+		SELECT nickname
+		FROM users_aliases
+		WHERE old_nickname = $1 AND expires > now()
+	`
+
+	var nickname string
+	err := r.DB.QueryRow(query, oldName).Scan(&nickname)
+	if err == pgx.ErrNoRows {
+		logger.Repo().Info(ctx, logger.Fields{"user": "not alias with nickname"})
+		return "", nil
+	}
+	if err != nil {
+		logger.Repo().AddFuncName("GetUserAlias").Error(ctx, err)
+		return "", err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"alias": nickname})
+	return nickname, nil
+}
+
+func (r *repo) DeactivateUser(ctx context.Context, name string) error {
+	query :=
+		`
+		UPDATE users SET deactivated = TRUE
+		WHERE nickname = $1
+	`
+
+	_, err := r.DB.Exec(query, name)
+	if err != nil {
+		logger.Repo().AddFuncName("DeactivateUser").Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repo) EraseUser(ctx context.Context, name, erasedName string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		logger.Repo().AddFuncName("EraseUser").Error(ctx, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// никнейм в чужих ветках и ответах заменится через ON UPDATE CASCADE,
+	// профиль в forums_users затрёт триггер update_forum_user
+	query :=
+		`
+		UPDATE users
+		SET nickname = $1, fullname = '', about = '', email = $1 || '@erased.invalid', deactivated = TRUE
+		WHERE nickname = $2
+	`
+	_, err = tx.Exec(query, erasedName, name)
+	if err != nil {
+		logger.Repo().AddFuncName("EraseUser").Error(ctx, err)
+		return err
+	}
+
+	query =
+		`
+		DELETE FROM users_aliases
+		WHERE nickname = $1
+	`
+	_, err = tx.Exec(query, erasedName)
+	if err != nil {
+		logger.Repo().AddFuncName("EraseUser").Error(ctx, err)
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Repo().AddFuncName("EraseUser").Error(ctx, err)
+		return err
+	}
+
+	logger.Repo().Debug(ctx, logger.Fields{"erased nickname": erasedName})
+	return nil
+}
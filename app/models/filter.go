@@ -0,0 +1,39 @@
+package models
+
+const (
+	FilterWords     = "words"
+	FilterLinks     = "links"
+	FilterLength    = "length"
+	FilterDuplicate = "duplicate"
+)
+
+const (
+	ActionReject = "reject"
+	ActionMask   = "mask"
+	ActionQueue  = "queue"
+)
+
+type FilterRule struct {
+	Id      int    `json:"id"`
+	Forum   string `json:"forum"`
+	Kind    string `json:"kind"`
+	Action  string `json:"action"`
+	Pattern string `json:"pattern"`
+	Limit   int    `json:"limit"`
+}
+
+type FilterContent struct {
+	Id      int64
+	Forum   string
+	Author  string
+	Title   string
+	Message string
+	Thread  bool
+	Edit    bool
+	Queued  bool
+}
+
+type FilterRejection struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
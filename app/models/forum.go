@@ -1,23 +1,124 @@
 package models
 
+const (
+	StatusApproved = "approved"
+	StatusPending  = "pending"
+	StatusRejected = "rejected"
+)
+
+const (
+	SortCreated  = "created"
+	SortVotes    = "votes"
+	SortActivity = "activity"
+	SortReplies  = "replies"
+	SortHot      = "hot"
+)
+
+const (
+	DepthReject  = "reject"  // слишком глубокий ответ отклоняется
+	DepthFlatten = "flatten" // ответ цепляется к самому глубокому допустимому предку
+)
+
+const (
+	RoleOwner     = "owner"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+	RoleBanned    = "banned"
+)
+
 type Forum struct {
-	Title   string `json:"title"`
-	User    string `json:"user"`
-	Slug    string `json:"slug"`
-	Posts   int    `json:"posts"`
-	Threads int    `json:"threads"`
+	Title        string  `json:"title"`
+	Description  string  `json:"description"`
+	User         string  `json:"user"`
+	Slug         string  `json:"slug"`
+	Category     string  `json:"category"`
+	Parent       *string `json:"parent"`
+	Archived     bool    `json:"archived"`
+	Moderation   bool    `json:"moderation"`
+	Posts        int     `json:"posts"`
+	Threads      int     `json:"threads"`
+	TotalPosts   int     `json:"totalPosts"`
+	TotalThreads int     `json:"totalThreads"`
+	Children     []Forum `json:"children,omitempty"`
+	ForumLimits
+}
+
+type ForumCategory struct {
+	Category     string  `json:"category"`
+	Forums       []Forum `json:"forums"`
+	TotalPosts   int     `json:"totalPosts"`
+	TotalThreads int     `json:"totalThreads"`
 }
 
 type ForumUsers struct {
 	Slug  string `json:"slug"`
-	Limit string `json:"limit"`
+	Limit int    `json:"limit"`
 	Since string `json:"since"`
 	Desc  bool   `json:"desc"`
 }
 
 type ForumThreads struct {
+	Slug   string `json:"slug"`
+	Tag    string `json:"tag"`
+	Sort   string `json:"sort"`
+	Viewer string `json:"viewer"`
+	Limit  int    `json:"limit"`
+	Since  string `json:"since"`
+	Desc   bool   `json:"desc"`
+}
+
+type ArchiveForumRequest struct {
+	Archived bool `json:"archived"`
+}
+
+type RenameForumRequest struct {
+	Slug string `json:"slug"`
+}
+
+type ForumRole struct {
+	Forum    string `json:"forum"`
+	Nickname string `json:"nickname"`
+	Role     string `json:"role"`
+}
+
+func IsModerator(role string) bool {
+	return role == RoleOwner || role == RoleModerator
+}
+
+type ForumLimits struct {
+	MaxDepth       int    `json:"maxDepth"`
+	MaxThreadPosts int    `json:"maxThreadPosts"`
+	DepthPolicy    string `json:"depthPolicy"`
+}
+
+// Validate подставляет политику по умолчанию и проверяет значения
+func (l *ForumLimits) Validate() bool {
+	if l.DepthPolicy == "" {
+		l.DepthPolicy = DepthReject
+	}
+
+	return l.MaxDepth >= 0 && l.MaxThreadPosts >= 0 &&
+		(l.DepthPolicy == DepthReject || l.DepthPolicy == DepthFlatten)
+}
+
+type ModerationForumRequest struct {
+	Moderation bool `json:"moderation"`
+}
+
+// ForumQueuePage - страница очереди модерации, пустой Kind - обе очереди сразу
+type ForumQueuePage struct {
 	Slug  string `json:"slug"`
+	Kind  string `json:"kind"`
 	Limit int    `json:"limit"`
 	Since string `json:"since"`
 	Desc  bool   `json:"desc"`
 }
+
+type ForumQueue struct {
+	Threads *[]Thread `json:"threads"`
+	Posts   *[]Post   `json:"posts"`
+}
+
+type QueueActionRequest struct {
+	Action string `json:"action"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type Post struct {
 	Id       int64     `json:"id"`
@@ -11,6 +14,8 @@ type Post struct {
 	Forum    string    `json:"forum"`
 	Thread   int       `json:"thread"`
 	Created  time.Time `json:"created"`
+	Depth    int       `json:"depth"` // 0 у корневого поста
+	Pending  bool      `json:"pending,omitempty"`
 }
 
 type RequestPost struct {
@@ -18,6 +23,27 @@ type RequestPost struct {
 	Related string `json:"related"`
 }
 
+// PostRelated - какие связанные с постом объекты отдать вместе с ним
+type PostRelated struct {
+	User   bool
+	Forum  bool
+	Thread bool
+}
+
+// ParseRelated разбирает related из query, лишние значения молча игнорируются
+func ParseRelated(related string) PostRelated {
+	return PostRelated{
+		User:   strings.Contains(related, "user"),
+		Forum:  strings.Contains(related, "forum"),
+		Thread: strings.Contains(related, "thread"),
+	}
+}
+
+type PostsDetailsRequest struct {
+	Ids     []int64  `json:"ids"`
+	Related []string `json:"related"` // user, forum, thread
+}
+
 type InfoPost struct {
 	Post   *Post   `json:"post"`
 	User   *User   `json:"author"`
@@ -30,6 +56,26 @@ type MessagePostRequest struct {
 	Message string `json:"message"`
 }
 
+type SplitPostRequest struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Slug    string `json:"slug"`
+	Forum   string `json:"forum"` // пусто - форум исходной ветки
+}
+
+type InfoSplit struct {
+	Thread      *Thread `json:"thread"`
+	Placeholder int64   `json:"placeholder"` // пост-ссылка, оставленный в исходной ветке
+}
+
+type PostReplies struct {
+	Id    int    `json:"id"`
+	Depth int    `json:"depth"` // 0 - без ограничения, 1 - только прямые ответы
+	Limit int    `json:"limit"`
+	Since string `json:"since"`
+	Desc  bool   `json:"desc"`
+}
+
 type Nesting struct {
 	Parent []int64
 	Last   []int64
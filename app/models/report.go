@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+const (
+	ReportPost   = "post"
+	ReportThread = "thread"
+	ReportUser   = "user"
+)
+
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+const (
+	ReportActionDismiss = "dismiss"
+	ReportActionResolve = "resolve"
+	ReportActionHide    = "hide"
+	ReportActionBan     = "ban"
+)
+
+type Report struct {
+	Id         int       `json:"id"`
+	Kind       string    `json:"kind"`
+	Forum      string    `json:"forum"`
+	Reporter   string    `json:"reporter"`
+	Reason     string    `json:"reason"`
+	Status     string    `json:"status"`
+	Post       *int64    `json:"post,omitempty"`
+	Thread     *int      `json:"thread,omitempty"`
+	User       *string   `json:"user,omitempty"`
+	Created    time.Time `json:"created"`
+	ResolvedBy *string   `json:"resolvedBy,omitempty"`
+	Action     string    `json:"action,omitempty"`
+}
+
+type InfoReport struct {
+	Report *Report `json:"report"`
+	Post   *Post   `json:"post,omitempty"`
+	Thread *Thread `json:"thread,omitempty"`
+	User   *User   `json:"user,omitempty"`
+}
+
+type ReportRequest struct {
+	Reporter string `json:"reporter"`
+	Reason   string `json:"reason"`
+	Forum    string `json:"forum"` // только для жалоб на пользователя
+}
+
+type ResolveReportRequest struct {
+	Action string `json:"action"`
+}
@@ -6,3 +6,7 @@ type InfoStatus struct {
 	Thread int `json:"thread"`
 	Post   int `json:"post"`
 }
+
+type InfoResync struct {
+	Changed int64 `json:"changed"`
+}
@@ -1,23 +1,95 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+const (
+	MaxThreadTags   = 10
+	MaxThreadTagLen = 32
+)
 
 type Thread struct {
-	Id      int        `json:"id"`
-	Title   string     `json:"title"`
-	Author  string     `json:"author"`
-	Forum   string     `json:"forum"`
-	Message string     `json:"message"`
-	Votes   int        `json:"votes"`
-	Slug    string     `json:"slug"`
-	Created *time.Time `json:"created"`
+	Id         int        `json:"id"`
+	Title      string     `json:"title"`
+	Author     string     `json:"author"`
+	Forum      string     `json:"forum"`
+	Message    string     `json:"message"`
+	Votes      int        `json:"votes"`
+	Slug       string     `json:"slug"`
+	Created    *time.Time `json:"created"`
+	Closed     bool       `json:"closed"`
+	Tags       []string   `json:"tags,omitempty"`
+	Posts      int        `json:"posts"`
+	LastPostAt *time.Time `json:"lastPostAt,omitempty"`
+	Views      int        `json:"views"`
+	Unread     *int       `json:"unread,omitempty"` // только при viewer в запросе
+	Pending    bool       `json:"pending,omitempty"`
 }
 
 type ThreadPosts struct {
 	SlugOrId string `json:"slug"`
-	Limit    string `json:"limit"`
+	Viewer   string `json:"viewer"`
+	Limit    int    `json:"limit"`
 	Since    string `json:"since"`
 	Sort     string `json:"sort"`
 	Desc     bool   `json:"desc"`
 	ThreadId int
 }
+
+type ReadThreadRequest struct {
+	Nickname string `json:"nickname"`
+	Post     int64  `json:"post"` // 0 - отметить прочитанной всю ветку
+}
+
+type ThreadRead struct {
+	Nickname string `json:"nickname"`
+	Thread   int    `json:"thread"`
+	LastRead int64  `json:"lastRead"` // id последнего прочитанного поста
+}
+
+type MoveThreadRequest struct {
+	Forum string `json:"forum"`
+}
+
+type MergeThreadRequest struct {
+	Thread string `json:"thread"` // slug или id ветки, которая вливается в текущую
+}
+
+type TagThreads struct {
+	Tag   string `json:"tag"`
+	Limit int    `json:"limit"`
+	Since string `json:"since"`
+	Desc  bool   `json:"desc"`
+}
+
+type TagCount struct {
+	Tag     string `json:"tag"`
+	Threads int    `json:"threads"`
+}
+
+// NormalizeTags приводит теги к нижнему регистру и убирает пустые и повторы,
+// false если тегов или символов в теге больше допустимого
+func NormalizeTags(tags []string) ([]string, bool) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > MaxThreadTagLen {
+			return nil, false
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized, len(normalized) <= MaxThreadTags
+}
+
+type CloseThreadRequest struct {
+	Closed bool `json:"closed"`
+}
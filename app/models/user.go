@@ -1,8 +1,59 @@
 package models
 
+import "time"
+
 type User struct {
+	Nickname    string `json:"nickname"`
+	Fullname    string `json:"fullname"`
+	About       string `json:"about"`
+	Email       string `json:"email"`
+	Deactivated bool   `json:"-"`
+}
+
+type UsersList struct {
+	Query string `json:"query"`
+	Match string `json:"match"`
+	Limit int    `json:"limit"`
+	Since string `json:"since"`
+	Desc  bool   `json:"desc"`
+}
+
+type UserStats struct {
+	Nickname      string     `json:"nickname"`
+	Posts         int        `json:"posts"`
+	Threads       int        `json:"threads"`
+	Votes         int        `json:"votes"`
+	Forums        int        `json:"forums"`
+	FirstActivity *time.Time `json:"firstActivity"`
+	LastActivity  *time.Time `json:"lastActivity"`
+}
+
+type UserPosts struct {
+	Nickname      string `json:"nickname"`
+	Forum         string `json:"forum"`
+	Limit         int    `json:"limit"`
+	Since         string `json:"since"`
+	Desc          bool   `json:"desc"`
+	IncludeHidden bool   `json:"-"`
+}
+
+type UserThreads struct {
+	Nickname      string `json:"nickname"`
+	Forum         string `json:"forum"`
+	Limit         int    `json:"limit"`
+	Since         string `json:"since"`
+	Desc          bool   `json:"desc"`
+	IncludeHidden bool   `json:"-"`
+}
+
+type RenameUserRequest struct {
 	Nickname string `json:"nickname"`
-	Fullname string `json:"fullname"`
-	About    string `json:"about"`
-	Email    string `json:"email"`
+}
+
+type UserExport struct {
+	User    *User     `json:"user"`
+	Forums  *[]Forum  `json:"forums"`
+	Threads *[]Thread `json:"threads"`
+	Posts   *[]Post   `json:"posts"`
+	Votes   *[]Vote   `json:"votes"`
 }
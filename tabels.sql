@@ -1,5 +1,12 @@
 CREATE EXTENSION citext;
 
+DROP TABLE users_aliases CASCADE;
+DROP TABLE forums_aliases CASCADE;
+DROP TABLE threads_aliases CASCADE;
+DROP TABLE forums_roles CASCADE;
+DROP TABLE forums_filters CASCADE;
+DROP TABLE reports CASCADE;
+DROP TABLE threads_reads CASCADE;
 DROP TABLE forums_users CASCADE;
 DROP TABLE votes CASCADE;
 DROP TABLE posts CASCADE;
@@ -11,27 +18,45 @@ CREATE UNLOGGED TABLE users (
     nickname CITEXT UNIQUE NOT NULL COLLATE "POSIX",
     fullname TEXT,
     about TEXT,
-    email CITEXT UNIQUE
+    email CITEXT UNIQUE,
+    deactivated BOOLEAN DEFAULT FALSE NOT NULL
 );
 
 CREATE UNLOGGED TABLE forums (
     id SERIAL PRIMARY KEY,
-    user_create CITEXT REFERENCES users(nickname) ON DELETE CASCADE NOT NULL,
+    user_create CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
     title TEXT,
     slug CITEXT UNIQUE NOT NULL, -- человекочетаемый URL
+    description TEXT DEFAULT '' NOT NULL,
+    archived BOOLEAN DEFAULT FALSE NOT NULL, -- форум только для чтения
+    moderation BOOLEAN DEFAULT FALSE NOT NULL, -- новые ветки и посты ждут одобрения модератора
+    max_depth INTEGER DEFAULT 0 NOT NULL CHECK (max_depth >= 0), -- 0 - без ограничения
+    max_thread_posts INTEGER DEFAULT 0 NOT NULL CHECK (max_thread_posts >= 0), -- одобренных постов в ветке, 0 - без ограничения
+    depth_policy TEXT DEFAULT 'reject' NOT NULL CHECK (depth_policy IN ('reject', 'flatten')), -- что делать с слишком глубоким ответом
+    category TEXT DEFAULT '' NOT NULL,
+    parent CITEXT REFERENCES forums(slug) ON DELETE SET NULL ON UPDATE CASCADE DEFAULT NULL,
+    path INTEGER[] DEFAULT '{}' NOT NULL, -- id предков от корня и свой, для счётчиков без рекурсии
     threads INTEGER DEFAULT 0 NOT NULL,
-    posts INTEGER DEFAULT 0 NOT NULL
+    posts INTEGER DEFAULT 0 NOT NULL,
+    threads_total INTEGER DEFAULT 0 NOT NULL, -- вместе с подфорумами
+    posts_total INTEGER DEFAULT 0 NOT NULL
 );
 
 CREATE UNLOGGED TABLE threads (
     id SERIAL PRIMARY KEY,
     title TEXT,
-    user_create CITEXT REFERENCES users(nickname) ON DELETE CASCADE NOT NULL,
-    forum CITEXT REFERENCES forums(slug) ON DELETE CASCADE ,
+    user_create CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    forum CITEXT REFERENCES forums(slug) ON DELETE CASCADE ON UPDATE CASCADE ,
     message TEXT, -- описание ветки
     votes INTEGER DEFAULT 0 NOT NULL,
     slug CITEXT NOT NULL,
-    created TIMESTAMP with time zone
+    created TIMESTAMP with time zone,
+    closed BOOLEAN DEFAULT FALSE NOT NULL, -- закрытая ветка не принимает новые посты
+    tags TEXT[] DEFAULT '{}' NOT NULL, -- в нижнем регистре, без повторов
+    posts INTEGER DEFAULT 0 NOT NULL, -- одобренные посты ветки
+    last_post_at TIMESTAMP with time zone DEFAULT now() NOT NULL, -- время последнего поста или создания ветки
+    views INTEGER DEFAULT 0 NOT NULL, -- пишется пачками из памяти сервера
+    status TEXT DEFAULT 'approved' NOT NULL CHECK (status IN ('approved', 'pending', 'rejected'))
 );
 
 CREATE UNLOGGED TABLE posts (
@@ -39,38 +64,125 @@ CREATE UNLOGGED TABLE posts (
     title TEXT,
     root_id INTEGER NOT NULL,
     parent INTEGER REFERENCES posts(id) DEFAULT NULL,
-    forum CITEXT REFERENCES forums(slug) ON DELETE CASCADE NOT NULL,
-    user_create CITEXT REFERENCES users(nickname) ON DELETE CASCADE NOT NULL,
+    forum CITEXT REFERENCES forums(slug) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    user_create CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
     thread INTEGER REFERENCES threads(id) ON DELETE CASCADE NOT NULL,
     created TIMESTAMP with time zone,
     message TEXT,
     is_edited BOOLEAN DEFAULT FALSE,
-    tree INTEGER[]
+    tree INTEGER[],
+    status TEXT DEFAULT 'approved' NOT NULL CHECK (status IN ('approved', 'pending', 'rejected'))
 );
 
 CREATE UNLOGGED TABLE votes (
     id SERIAL PRIMARY KEY,
-    user_create CITEXT REFERENCES users(nickname) ON DELETE CASCADE NOT NULL,
+    user_create CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
     thread INTEGER REFERENCES threads(id) ON DELETE CASCADE NOT NULL,
     voice INTEGER NOT NULL,
     UNIQUE (user_create, thread)
 );
 
 CREATE UNLOGGED TABLE forums_users (
-    user_nickname CITEXT REFERENCES users(nickname) ON DELETE CASCADE NOT NULL COLLATE "POSIX",
+    user_nickname CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL COLLATE "POSIX",
     user_fullname TEXT,
     user_about TEXT,
     user_email CITEXT,
-    forum CITEXT REFERENCES forums(slug) ON DELETE CASCADE NOT NULL,
+    forum CITEXT REFERENCES forums(slug) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
     UNIQUE (user_nickname, forum)
 );
 
+-- роли пользователей в форуме, автор форума считается owner и без записи здесь
+CREATE UNLOGGED TABLE forums_roles (
+    forum CITEXT REFERENCES forums(slug) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    user_nickname CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL COLLATE "POSIX",
+    role TEXT NOT NULL CHECK (role IN ('owner', 'moderator', 'member', 'banned')),
+    PRIMARY KEY (forum, user_nickname)
+);
+
+-- правила фильтрации текста постов и веток в форуме
+CREATE UNLOGGED TABLE forums_filters (
+    id SERIAL PRIMARY KEY,
+    forum CITEXT REFERENCES forums(slug) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('words', 'links', 'length', 'duplicate')),
+    action TEXT NOT NULL CHECK (action IN ('reject', 'mask', 'queue')),
+    pattern TEXT DEFAULT '' NOT NULL, -- регулярное выражение для words
+    lim INTEGER DEFAULT 0 NOT NULL -- ссылок, символов или минут для duplicate
+);
+
+-- до какого поста пользователь дочитал ветку
+CREATE UNLOGGED TABLE threads_reads (
+    user_nickname CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL COLLATE "POSIX",
+    thread INTEGER REFERENCES threads(id) ON DELETE CASCADE NOT NULL,
+    last_read BIGINT DEFAULT 0 NOT NULL,
+    PRIMARY KEY (user_nickname, thread)
+);
+
+-- жалобы на посты, ветки и пользователей, разбираются модераторами форума
+CREATE UNLOGGED TABLE reports (
+    id SERIAL PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('post', 'thread', 'user')),
+    forum CITEXT REFERENCES forums(slug) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    reporter CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    reason TEXT NOT NULL,
+    post INTEGER REFERENCES posts(id) ON DELETE CASCADE DEFAULT NULL,
+    thread INTEGER REFERENCES threads(id) ON DELETE CASCADE DEFAULT NULL,
+    user_nickname CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE DEFAULT NULL,
+    status TEXT DEFAULT 'open' NOT NULL CHECK (status IN ('open', 'resolved', 'dismissed')),
+    created TIMESTAMP with time zone DEFAULT now() NOT NULL,
+    resolved_by CITEXT REFERENCES users(nickname) ON DELETE SET NULL ON UPDATE CASCADE DEFAULT NULL,
+    action TEXT DEFAULT '' NOT NULL
+);
+
+-- старые slug форумов после переименования, чтобы старые ссылки продолжали работать
+CREATE UNLOGGED TABLE forums_aliases (
+    old_slug CITEXT PRIMARY KEY,
+    slug CITEXT REFERENCES forums(slug) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL
+);
+
+-- slug веток, слитых в другие: старые ссылки ведут в ветку, куда их слили
+CREATE UNLOGGED TABLE threads_aliases (
+    old_slug CITEXT PRIMARY KEY,
+    thread INTEGER REFERENCES threads(id) ON DELETE CASCADE NOT NULL
+);
+
+-- старые никнеймы пользователей после переименования, живут до expires
+CREATE UNLOGGED TABLE users_aliases (
+    old_nickname CITEXT PRIMARY KEY COLLATE "POSIX",
+    nickname CITEXT REFERENCES users(nickname) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    expires TIMESTAMP with time zone NOT NULL
+);
+
 
 CREATE OR REPLACE FUNCTION add_tree() RETURNS TRIGGER AS
 $add_tree$
 declare
     parents INTEGER[];
+    limits RECORD;
+    thread_forum CITEXT;
+    thread_posts INTEGER;
 begin
+    -- одобренный пост сразу учитывается в threads.posts: UPDATE блокирует строку ветки,
+    -- поэтому параллельные вставки и строки этого же INSERT видят уже увеличенный счётчик.
+    -- Форум берём из той же строки: если ветку переносят, вставка дождётся конца переноса
+    if (new.status = 'approved') then
+        update threads t set posts = t.posts + 1, last_post_at = greatest(t.last_post_at, new.created)
+        where t.id = new.thread
+        returning t.forum, t.posts into thread_forum, thread_posts;
+    else
+        select t.forum, t.posts + 1 from threads t where t.id = new.thread for no key update
+        into thread_forum, thread_posts;
+    end if;
+    if (found) then
+        new.forum := thread_forum;
+    end if;
+
+    select f.max_depth, f.max_thread_posts, f.depth_policy from forums f where f.slug = new.forum
+    into limits;
+
+    if (limits.max_thread_posts > 0 and thread_posts > limits.max_thread_posts) then
+        raise exception 'thread has reached % posts', limits.max_thread_posts USING ERRCODE = '12348';
+    end if;
+
     if (new.parent is null) then
         new.tree := new.tree || new.id;
         new.root_id := new.tree[1];
@@ -82,6 +194,16 @@ begin
             raise exception 'parent post not exists' USING ERRCODE = '12345';
         end if;
 
+        -- глубина нового поста равна длине пути родителя
+        if (limits.max_depth > 0 and array_length(parents, 1) > limits.max_depth) then
+            if (limits.depth_policy = 'flatten') then
+                parents := parents[1:limits.max_depth];
+                new.parent := parents[limits.max_depth];
+            else
+                raise exception 'post depth exceeds %', limits.max_depth USING ERRCODE = '12347';
+            end if;
+        end if;
+
         new.tree := new.tree || parents || new.id;
         new.root_id := new.tree[1];
     end if;
@@ -93,32 +215,140 @@ create trigger add_path
     before insert on posts for each row
 execute procedure add_tree();
 
--- функция и триггер при создании поста, на увеличение кол-ва постов в forums
+-- проверка лимитов целевой ветки перед слиянием: посты source уходят на уровень ниже,
+-- плюс корневой пост из текста source
+CREATE OR REPLACE FUNCTION check_merge_limits(target INTEGER, source INTEGER) RETURNS VOID AS
+$check_merge_limits$
+declare
+    limits RECORD;
+begin
+    select f.max_depth, f.max_thread_posts from forums f join threads t on t.forum = f.slug where t.id = target
+    into limits;
+
+    if (limits.max_depth > 0 and
+        (select max(array_length(p.tree, 1)) from posts p where p.thread = source) > limits.max_depth) then
+        raise exception 'merged posts would exceed depth %', limits.max_depth USING ERRCODE = '12347';
+    end if;
+
+    if (limits.max_thread_posts > 0 and
+        (select sum(t.posts) from threads t where t.id in (target, source)) + 1 > limits.max_thread_posts) then
+        raise exception 'merged thread would exceed % posts', limits.max_thread_posts USING ERRCODE = '12348';
+    end if;
+end;
+$check_merge_limits$ LANGUAGE plpgsql;
+
+-- функция и триггер при создании форума и смене parent, на пересчёт path у форума и его подфорумов
+CREATE OR REPLACE FUNCTION set_forum_path() RETURNS TRIGGER AS
+$set_forum_path$
+BEGIN
+    NEW.path := coalesce((SELECT f.path FROM forums AS f WHERE f.slug = NEW.parent), '{}') || NEW.id;
+    RETURN NEW;
+END
+$set_forum_path$ LANGUAGE plpgsql;
+
+CREATE TRIGGER set_forum_path
+BEFORE INSERT OR UPDATE OF parent ON forums
+    FOR EACH ROW EXECUTE PROCEDURE set_forum_path();
+
+CREATE OR REPLACE FUNCTION move_forum_path() RETURNS TRIGGER AS
+$move_forum_path$
+BEGIN
+    UPDATE forums
+    SET path = NEW.path || path[array_position(path, NEW.id) + 1:]
+    WHERE path @> ARRAY[NEW.id] AND id <> NEW.id;
+    RETURN NULL;
+END
+$move_forum_path$ LANGUAGE plpgsql;
+
+CREATE TRIGGER move_forum_path
+AFTER UPDATE OF parent ON forums
+    FOR EACH ROW WHEN (OLD.path IS DISTINCT FROM NEW.path) EXECUTE PROCEDURE move_forum_path();
+
+-- сдвиг счётчиков форума и его предков при переносе веток и постов между форумами
+CREATE OR REPLACE FUNCTION shift_forum_counters(forum_slug CITEXT, threads_delta INTEGER, posts_delta INTEGER) RETURNS VOID AS
+$shift_forum_counters$
+    UPDATE forums
+    SET threads = threads + (forums.slug = forum_slug)::int * threads_delta, threads_total = threads_total + threads_delta,
+        posts = posts + (forums.slug = forum_slug)::int * posts_delta, posts_total = posts_total + posts_delta
+    WHERE forums.id = ANY((SELECT f.path FROM forums AS f WHERE f.slug = forum_slug));
+$shift_forum_counters$ LANGUAGE sql;
+
+-- оценка для sort=hot: голоса в логарифме плюс свежесть, от now() не зависит, поэтому пагинация стабильна
+CREATE OR REPLACE FUNCTION thread_hot(votes INTEGER, last_post_at TIMESTAMP with time zone) RETURNS DOUBLE PRECISION AS
+$thread_hot$
+    SELECT sign(votes) * log(greatest(abs(votes), 1)) + extract(epoch FROM last_post_at) / 45000;
+$thread_hot$ LANGUAGE sql IMMUTABLE;
+
+-- функция и триггер при создании поста, на увеличение кол-ва постов в forums и его предках и в ветке
 CREATE OR REPLACE FUNCTION insert_post() RETURNS TRIGGER AS
 $insert_post$
 BEGIN
-    UPDATE forums SET posts=posts + 1 WHERE forums.slug = NEW.forum;
+    UPDATE forums SET posts = posts + (forums.slug = NEW.forum)::int, posts_total = posts_total + 1
+    WHERE forums.id = ANY((SELECT f.path FROM forums AS f WHERE f.slug = NEW.forum));
+    -- при вставке ветку уже обновил add_tree
+    IF (TG_OP = 'UPDATE') THEN
+        UPDATE threads SET posts = posts + 1, last_post_at = greatest(last_post_at, NEW.created)
+        WHERE id = NEW.thread;
+    END IF;
     RETURN NEW;
 END
 $insert_post$ LANGUAGE plpgsql;
 
+-- посты на модерации учитываются только после одобрения
 CREATE TRIGGER insert_post
 AFTER INSERT ON posts
-    FOR EACH ROW EXECUTE PROCEDURE insert_post();
+    FOR EACH ROW WHEN (NEW.status = 'approved') EXECUTE PROCEDURE insert_post();
 
+CREATE TRIGGER approve_post
+AFTER UPDATE OF status ON posts
+    FOR EACH ROW WHEN (OLD.status <> 'approved' AND NEW.status = 'approved') EXECUTE PROCEDURE insert_post();
 
--- функция и триггер при создании ветки, на увеличение кол-ва веток в forums
+-- скрытый по жалобе пост перестаёт учитываться в счётчиках
+CREATE OR REPLACE FUNCTION hide_post() RETURNS TRIGGER AS
+$hide_post$
+BEGIN
+    UPDATE forums SET posts = posts - (forums.slug = NEW.forum)::int, posts_total = posts_total - 1
+    WHERE forums.id = ANY((SELECT f.path FROM forums AS f WHERE f.slug = NEW.forum));
+    UPDATE threads SET posts = posts - 1 WHERE id = NEW.thread;
+    RETURN NEW;
+END
+$hide_post$ LANGUAGE plpgsql;
+
+CREATE TRIGGER hide_post
+AFTER UPDATE OF status ON posts
+    FOR EACH ROW WHEN (OLD.status = 'approved' AND NEW.status <> 'approved') EXECUTE PROCEDURE hide_post();
+
+
+-- функция и триггер при создании ветки, на увеличение кол-ва веток в forums и его предках
 CREATE OR REPLACE FUNCTION insert_thread() RETURNS TRIGGER AS
 $insert_thread$
 BEGIN
-    UPDATE forums SET threads=threads + 1 WHERE forums.slug = NEW.forum;
+    UPDATE forums SET threads = threads + (forums.slug = NEW.forum)::int, threads_total = threads_total + 1
+    WHERE forums.id = ANY((SELECT f.path FROM forums AS f WHERE f.slug = NEW.forum));
     RETURN NEW;
 END
 $insert_thread$ LANGUAGE plpgsql;
 
 CREATE TRIGGER insert_thread
 AFTER INSERT ON threads
-    FOR EACH ROW EXECUTE PROCEDURE insert_thread();
+    FOR EACH ROW WHEN (NEW.status = 'approved') EXECUTE PROCEDURE insert_thread();
+
+CREATE TRIGGER approve_thread
+AFTER UPDATE OF status ON threads
+    FOR EACH ROW WHEN (OLD.status <> 'approved' AND NEW.status = 'approved') EXECUTE PROCEDURE insert_thread();
+
+CREATE OR REPLACE FUNCTION hide_thread() RETURNS TRIGGER AS
+$hide_thread$
+BEGIN
+    UPDATE forums SET threads = threads - (forums.slug = NEW.forum)::int, threads_total = threads_total - 1
+    WHERE forums.id = ANY((SELECT f.path FROM forums AS f WHERE f.slug = NEW.forum));
+    RETURN NEW;
+END
+$hide_thread$ LANGUAGE plpgsql;
+
+CREATE TRIGGER hide_thread
+AFTER UPDATE OF status ON threads
+    FOR EACH ROW WHEN (OLD.status = 'approved' AND NEW.status <> 'approved') EXECUTE PROCEDURE hide_thread();
 
 
 -- функция и триггер при создании ветки и поста, на добавления пользователя в список форума
@@ -147,12 +377,68 @@ $new_forum_user_added$ LANGUAGE plpgsql;
 DROP TRIGGER IF EXISTS new_forum_user_added ON posts;
 CREATE TRIGGER new_forum_user_added
     AFTER INSERT ON posts
-    FOR EACH ROW EXECUTE PROCEDURE new_forum_user_added();
+    FOR EACH ROW WHEN (NEW.status = 'approved') EXECUTE PROCEDURE new_forum_user_added();
+
+CREATE TRIGGER approve_forum_user
+    AFTER UPDATE OF status ON posts
+    FOR EACH ROW WHEN (OLD.status <> 'approved' AND NEW.status = 'approved') EXECUTE PROCEDURE new_forum_user_added();
 
 DROP TRIGGER IF EXISTS new_forum_user_added ON threads;
 CREATE TRIGGER new_forum_user_added
     AFTER INSERT ON threads
-    FOR EACH ROW EXECUTE PROCEDURE new_forum_user_added();
+    FOR EACH ROW WHEN (NEW.status = 'approved') EXECUTE PROCEDURE new_forum_user_added();
+
+CREATE TRIGGER approve_forum_user
+    AFTER UPDATE OF status ON threads
+    FOR EACH ROW WHEN (OLD.status <> 'approved' AND NEW.status = 'approved') EXECUTE PROCEDURE new_forum_user_added();
+
+-- функция и триггер при обновлении профиля, на синхронизацию данных пользователя в forums_users
+CREATE OR REPLACE FUNCTION update_forum_user() RETURNS TRIGGER AS
+$update_forum_user$
+BEGIN
+    UPDATE forums_users
+    SET user_fullname = NEW.fullname, user_about = NEW.about, user_email = NEW.email
+    WHERE user_nickname = NEW.nickname;
+
+    RETURN NULL;
+END
+$update_forum_user$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_forum_user
+AFTER UPDATE OF fullname, about, email ON users
+    FOR EACH ROW
+    WHEN (OLD.fullname IS DISTINCT FROM NEW.fullname
+        OR OLD.about IS DISTINCT FROM NEW.about
+        OR OLD.email IS DISTINCT FROM NEW.email)
+    EXECUTE PROCEDURE update_forum_user();
+
+-- функция и триггер при создании поста, ветки и голоса, на запрет действий деактивированным пользователям
+CREATE OR REPLACE FUNCTION check_user_active() RETURNS TRIGGER AS
+$check_user_active$
+BEGIN
+    -- служебные посты (корневой пост слияния) пишутся от имени исходного автора, даже удалённого
+    IF current_setting('forums.system_post', true) = 'on' THEN
+        RETURN NEW;
+    END IF;
+    IF EXISTS (SELECT 1 FROM users WHERE nickname = NEW.user_create AND deactivated) THEN
+        RAISE EXCEPTION 'user is deactivated' USING ERRCODE = '12346';
+    END IF;
+    RETURN NEW;
+END
+$check_user_active$ LANGUAGE plpgsql;
+
+CREATE TRIGGER check_user_active
+BEFORE INSERT ON posts
+    FOR EACH ROW EXECUTE PROCEDURE check_user_active();
+
+CREATE TRIGGER check_user_active
+BEFORE INSERT ON threads
+    FOR EACH ROW EXECUTE PROCEDURE check_user_active();
+
+-- UPDATE OF voice, чтобы не срабатывать на каскадное изменение никнейма
+CREATE TRIGGER check_user_active
+BEFORE INSERT OR UPDATE OF voice ON votes
+    FOR EACH ROW EXECUTE PROCEDURE check_user_active();
 
 -- функция и триггер при создании голоса, на увеличение кол-ва голосов в threads
 CREATE OR REPLACE FUNCTION insert_voice() RETURNS TRIGGER AS
@@ -185,6 +471,10 @@ AFTER UPDATE ON votes
 
 -- index
 CREATE INDEX IF NOT EXISTS forum_slug ON forums using hash (slug);
+CREATE INDEX IF NOT EXISTS forum_parent ON forums (parent); -- для получения подфорумов
+CREATE INDEX IF NOT EXISTS forum_path ON forums using gin (path); -- подфорумы при смене parent
+CREATE INDEX IF NOT EXISTS forums_filters_forum ON forums_filters (forum);
+CREATE INDEX IF NOT EXISTS reports_open ON reports (forum, id) where status = 'open'; -- открытые жалобы форума
 
 -- CREATE INDEX IF NOT EXISTS forums_user_user ON forums_users (user_nickname); -- подумать надо ли
 -- CREATE INDEX IF NOT EXISTS forums_user_forum ON forums_users (forum); -- не факт что нужно после изменения схемы бд
@@ -203,11 +493,22 @@ CREATE INDEX IF NOT EXISTS thr_slug ON threads using hash (slug);
 -- CREATE INDEX IF NOT EXISTS thr_forum_created on threads (forum, created);
 -- CREATE INDEX IF NOT EXISTS thr_all on threads (forum, created, id, slug, title, user_create, message, votes); -- тестовая
 CREATE INDEX IF NOT EXISTS thr_forum_created on threads (forum, created);
+CREATE INDEX IF NOT EXISTS thr_user_created on threads (user_create, created); -- для ленты веток пользователя
+CREATE INDEX IF NOT EXISTS thr_pending on threads (forum, id) where status = 'pending'; -- очередь модерации
+CREATE INDEX IF NOT EXISTS thr_tags on threads using gin (tags); -- фильтр по тегу и облако тегов
+-- sort=votes|activity|replies|hot с пагинацией по (ключ, id)
+CREATE INDEX IF NOT EXISTS thr_forum_votes on threads (forum, votes, id) where status = 'approved';
+CREATE INDEX IF NOT EXISTS thr_forum_activity on threads (forum, last_post_at, id) where status = 'approved';
+CREATE INDEX IF NOT EXISTS thr_forum_replies on threads (forum, posts, id) where status = 'approved';
+CREATE INDEX IF NOT EXISTS thr_forum_hot on threads (forum, thread_hot(votes, last_post_at), id) where status = 'approved';
 
 create index idx_posts_thread on posts (thread);
 create index idx_posts_tree on posts using gin (tree);
 create index idx_posts_root_id on posts (root_id);
 create index idx_posts_forum on posts (forum);
+create index idx_posts_user_id on posts (user_create, id); -- для ленты постов пользователя
+create index idx_posts_pending on posts (forum, id) where status = 'pending'; -- очередь модерации
+create index idx_posts_thread_id on posts (thread, id) where status = 'approved'; -- непрочитанные посты ветки
 -- create index idx_posts_thread_tree2_id on posts (thread, (tree[2]), id);
 -- create index idx_posts_thread_tree on posts (thread, tree);
 
@@ -0,0 +1,88 @@
+package pagination
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/forums/utils/errors"
+)
+
+// Limits - размер страницы списка: Default без limit в запросе, больше Max нельзя
+type Limits struct {
+	Default int
+	Max     int
+}
+
+// SinceKind - чем является since у конкретного списка
+type SinceKind int
+
+const (
+	SinceString SinceKind = iota // например никнейм
+	SinceId                      // id последнего элемента прошлой страницы
+	SinceTime                    // дата создания в RFC3339
+)
+
+type Page struct {
+	Limit int
+	Since string
+	Desc  bool
+}
+
+// ParseLimit - только limit, для списков без since и desc
+func ParseLimit(query url.Values, limits Limits) (int, errors.Error) {
+	limit := query.Get("limit")
+	if limit == "" {
+		return limits.Default, nil
+	}
+
+	value, err := strconv.Atoi(limit)
+	if err != nil || value <= 0 {
+		return 0, errors.New(http.StatusBadRequest, "Invalid limit "+limit)
+	}
+	if value > limits.Max {
+		return 0, errors.New(http.StatusBadRequest, "Limit must not exceed "+strconv.Itoa(limits.Max))
+	}
+
+	return value, nil
+}
+
+// Parse разбирает limit, since и desc из запроса. Ошибки - всегда 400,
+// до SQL доходят только проверенные значения.
+func Parse(query url.Values, limits Limits, since SinceKind) (*Page, errors.Error) {
+	limit, limitErr := ParseLimit(query, limits)
+	if limitErr != nil {
+		return nil, limitErr
+	}
+
+	page := &Page{
+		Limit: limit,
+		Since: query.Get("since"),
+	}
+
+	switch desc := query.Get("desc"); desc {
+	case "", "false":
+	case "true":
+		page.Desc = true
+	default:
+		return nil, errors.New(http.StatusBadRequest, "Invalid desc "+desc)
+	}
+
+	if page.Since == "" {
+		return page, nil
+	}
+
+	switch since {
+	case SinceId:
+		if _, err := strconv.ParseInt(page.Since, 10, 64); err != nil {
+			return nil, errors.New(http.StatusBadRequest, "Since must be id, got "+page.Since)
+		}
+	case SinceTime:
+		if _, err := time.Parse(time.RFC3339, page.Since); err != nil {
+			return nil, errors.New(http.StatusBadRequest, "Since must be RFC3339 time, got "+page.Since)
+		}
+	}
+
+	return page, nil
+}
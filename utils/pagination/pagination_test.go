@@ -0,0 +1,92 @@
+package pagination
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	limits := Limits{Default: 100, Max: 1000}
+
+	cases := []struct {
+		name  string
+		query string
+		since SinceKind
+		page  Page
+		fail  bool
+	}{
+		{name: "defaults", query: "", page: Page{Limit: 100}},
+		{name: "limit", query: "limit=10", page: Page{Limit: 10}},
+		{name: "limit max", query: "limit=1000", page: Page{Limit: 1000}},
+		{name: "limit over max", query: "limit=1001", fail: true},
+		{name: "limit zero", query: "limit=0", fail: true},
+		{name: "limit negative", query: "limit=-1", fail: true},
+		{name: "limit not number", query: "limit=ten", fail: true},
+
+		{name: "desc true", query: "desc=true", page: Page{Limit: 100, Desc: true}},
+		{name: "desc false", query: "desc=false", page: Page{Limit: 100}},
+		{name: "desc empty", query: "desc=", page: Page{Limit: 100}},
+		{name: "desc other", query: "desc=1", fail: true},
+
+		{name: "since string", query: "since=nick", since: SinceString, page: Page{Limit: 100, Since: "nick"}},
+		{name: "since id", query: "since=42", since: SinceId, page: Page{Limit: 100, Since: "42"}},
+		{name: "since id not number", query: "since=nick", since: SinceId, fail: true},
+		{name: "since time", query: "since=2020-01-02T03:04:05Z", since: SinceTime,
+			page: Page{Limit: 100, Since: "2020-01-02T03:04:05Z"}},
+		{name: "since time not rfc3339", query: "since=2020-01-02", since: SinceTime, fail: true},
+		{name: "since empty for id", query: "since=", since: SinceId, page: Page{Limit: 100}},
+	}
+
+	for _, c := range cases {
+		query, err := url.ParseQuery(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		page, pageErr := Parse(query, limits, c.since)
+		if c.fail {
+			if pageErr == nil {
+				t.Errorf("%s: expected error, got %+v", c.name, *page)
+			} else if pageErr.Code() != 400 {
+				t.Errorf("%s: expected 400, got %d", c.name, pageErr.Code())
+			}
+			continue
+		}
+
+		if pageErr != nil {
+			t.Errorf("%s: unexpected error %v", c.name, pageErr)
+			continue
+		}
+		if *page != c.page {
+			t.Errorf("%s: got %+v, want %+v", c.name, *page, c.page)
+		}
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	limits := Limits{Default: 50, Max: 500}
+
+	cases := []struct {
+		query string
+		limit int
+		fail  bool
+	}{
+		{query: "", limit: 50},
+		{query: "limit=7", limit: 7},
+		{query: "limit=501", fail: true},
+		{query: "limit=0", fail: true},
+		{query: "since=x&desc=maybe", limit: 50}, // остальное не смотрит
+	}
+
+	for _, c := range cases {
+		query, _ := url.ParseQuery(c.query)
+		limit, err := ParseLimit(query, limits)
+		if c.fail != (err != nil) {
+			t.Errorf("%q: error = %v", c.query, err)
+			continue
+		}
+		if !c.fail && limit != c.limit {
+			t.Errorf("%q: got %d, want %d", c.query, limit, c.limit)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package prepared
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx"
+)
+
+// Запросы горячего пути. Репозитории регистрируют их при инициализации пакета,
+// AfterConnect готовит все запросы на каждом новом соединении пула,
+// а вызываются они по имени: pgx сам находит подготовленный запрос по строке.
+var statements = make(map[string]string)
+
+// Register запоминает запрос и возвращает имя, которое передаётся в Query/QueryRow
+func Register(name, sql string) string {
+	if _, ok := statements[name]; ok {
+		panic("prepared statement " + name + " registered twice")
+	}
+
+	statements[name] = sql
+	return name
+}
+
+// SQL - текст зарегистрированного запроса
+func SQL(name string) string {
+	return statements[name]
+}
+
+// AfterConnect для pgx.ConnPoolConfig. Первое соединение пул открывает сразу,
+// поэтому ошибка в любом запросе всплывает при старте сервера.
+func AfterConnect(conn *pgx.Conn) error {
+	names := make([]string, 0, len(statements))
+	for name := range statements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := conn.Prepare(name, statements[name]); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
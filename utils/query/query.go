@@ -0,0 +1,139 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Select собирает списочный SELECT. В условиях параметры пишутся как ?,
+// номера $n расставляет Build, поэтому подзапросы можно вкладывать друг в друга.
+type Select struct {
+	from    string
+	where   []string
+	args    []interface{}
+	groupBy string
+	order   []string
+	desc    bool
+	limit   int
+}
+
+// New принимает "SELECT ... FROM ..." без WHERE, ? в нём берутся из args
+func New(from string, args ...interface{}) *Select {
+	return &Select{from: from, args: args}
+}
+
+func (q *Select) Where(cond string, args ...interface{}) *Select {
+	q.where = append(q.where, cond)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// WhereSub подставляет подзапрос вместо единственного ? в cond
+func (q *Select) WhereSub(cond string, sub *Select) *Select {
+	text, args := sub.raw()
+	return q.Where(strings.Replace(cond, "?", "("+text+")", 1), args...)
+}
+
+// Desc задаёт направление для Since и OrderBy
+func (q *Select) Desc(desc bool) *Select {
+	q.desc = desc
+	return q
+}
+
+// Since - keyset-условие "key > value" (или < при Desc), value - выражение с ?.
+// Пустой since ничего не добавляет.
+func (q *Select) Since(key, value, since string) *Select {
+	if since == "" {
+		return q
+	}
+
+	op := " > "
+	if q.desc {
+		op = " < "
+	}
+	return q.Where(key+op+value, since)
+}
+
+// SinceInclusive - как Since, но со строгостью >= / <=
+func (q *Select) SinceInclusive(key, value, since string) *Select {
+	if since == "" {
+		return q
+	}
+
+	op := " >= "
+	if q.desc {
+		op = " <= "
+	}
+	return q.Where(key+op+value, since)
+}
+
+func (q *Select) GroupBy(expr string) *Select {
+	q.groupBy = expr
+	return q
+}
+
+// OrderBy добавляет ключи сортировки в заданном Desc направлении
+func (q *Select) OrderBy(keys ...string) *Select {
+	for _, key := range keys {
+		if q.desc {
+			key += " DESC"
+		}
+		q.order = append(q.order, key)
+	}
+	return q
+}
+
+// OrderAsc добавляет ключ, который всегда идёт по возрастанию
+func (q *Select) OrderAsc(keys ...string) *Select {
+	q.order = append(q.order, keys...)
+	return q
+}
+
+// Limit 0 - без ограничения
+func (q *Select) Limit(limit int) *Select {
+	q.limit = limit
+	return q
+}
+
+func (q *Select) raw() (string, []interface{}) {
+	var text strings.Builder
+	args := append([]interface{}{}, q.args...)
+
+	text.WriteString(q.from)
+	if len(q.where) != 0 {
+		text.WriteString(" WHERE ")
+		text.WriteString(strings.Join(q.where, " AND "))
+	}
+	if q.groupBy != "" {
+		text.WriteString(" GROUP BY ")
+		text.WriteString(q.groupBy)
+	}
+	if len(q.order) != 0 {
+		text.WriteString(" ORDER BY ")
+		text.WriteString(strings.Join(q.order, ", "))
+	}
+	if q.limit != 0 {
+		text.WriteString(" LIMIT ?")
+		args = append(args, q.limit)
+	}
+
+	return strings.Join(strings.Fields(text.String()), " "), args
+}
+
+// Build возвращает текст запроса с $1..$n и параметры в том же порядке
+func (q *Select) Build() (string, []interface{}) {
+	text, args := q.raw()
+
+	var result strings.Builder
+	n := 0
+	for _, r := range text {
+		if r == '?' {
+			n++
+			result.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		result.WriteRune(r)
+	}
+
+	return result.String(), args
+}
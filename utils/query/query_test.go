@@ -0,0 +1,49 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/forums/utils/query/querytest"
+)
+
+func TestSelect(t *testing.T) {
+	var cases []querytest.Case
+	add := func(name string, q *Select) {
+		text, args := q.Build()
+		cases = append(cases, querytest.Case{Name: name, Query: text, Args: args})
+	}
+
+	add("plain", New("SELECT a FROM t"))
+	add("from args", New("SELECT a, b = ? FROM t", 1).Where("c = ?", 2))
+	add("where", New("SELECT a FROM t").Where("b = ?", 1).Where("c IS NULL"))
+	add("group", New("SELECT a, count(*) FROM t").GroupBy("a").OrderAsc("count(*) DESC", "a"))
+
+	for _, desc := range []bool{false, true} {
+		for _, since := range []string{"", "5"} {
+			for _, limit := range []int{0, 10} {
+				name := caseName(desc, since, limit)
+				add("exclusive "+name, New("SELECT a FROM t").Desc(desc).Since("a", "?", since).OrderBy("a", "b").Limit(limit))
+				add("inclusive "+name, New("SELECT a FROM t").Desc(desc).SinceInclusive("a", "?", since).OrderBy("a").Limit(limit))
+			}
+		}
+	}
+
+	sub := New("SELECT id FROM t").Where("x = ?", 1).Desc(true).Since("id", "?", "9").OrderBy("id").Limit(3)
+	add("sub", New("SELECT a FROM t").Where("y = ?", 2).WhereSub("id IN ?", sub).Where("z = ?", 3).OrderAsc("a"))
+
+	querytest.Golden(t, "select", cases)
+}
+
+func caseName(desc bool, since string, limit int) string {
+	name := "asc"
+	if desc {
+		name = "desc"
+	}
+	if since != "" {
+		name += " since"
+	}
+	if limit != 0 {
+		name += " limit"
+	}
+	return name
+}
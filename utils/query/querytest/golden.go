@@ -0,0 +1,61 @@
+package querytest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "перезаписать golden-файлы запросов")
+
+type Case struct {
+	Name  string
+	Query string
+	Args  []interface{}
+}
+
+func (c Case) String() string {
+	return fmt.Sprintf("-- %s\n%s\nargs: %#v", c.Name, c.Query, c.Args)
+}
+
+// Golden сравнивает собранные запросы с testdata/<name>.golden,
+// go test -run ... -update перезаписывает файл
+func Golden(t *testing.T, name string, cases []Case) {
+	t.Helper()
+
+	got := make([]string, 0, len(cases))
+	for _, c := range cases {
+		got = append(got, c.String())
+	}
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(strings.Join(got, "\n\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden: %v (запустите с -update)", err)
+	}
+
+	want := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n\n")
+	if len(want) != len(got) {
+		t.Errorf("%s: want %d queries, got %d", path, len(want), len(got))
+	}
+
+	for i := 0; i < len(want) && i < len(got); i++ {
+		if want[i] != got[i] {
+			t.Errorf("%s: query #%d differs\nwant:\n%s\ngot:\n%s", path, i, want[i], got[i])
+		}
+	}
+}
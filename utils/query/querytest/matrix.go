@@ -0,0 +1,88 @@
+package querytest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Axis - параметр запроса и перебираемые значения
+type Axis struct {
+	Name   string
+	Values []interface{}
+}
+
+// Params - значения осей одного случая по именам
+type Params map[string]interface{}
+
+func (p Params) Bool(name string) bool {
+	v, _ := p[name].(bool)
+	return v
+}
+
+func (p Params) String(name string) string {
+	v, _ := p[name].(string)
+	return v
+}
+
+func (p Params) Int(name string) int {
+	v, _ := p[name].(int)
+	return v
+}
+
+// Оси постраничных списков, общие для всех репозиториев
+
+func Desc() Axis {
+	return Axis{Name: "desc", Values: []interface{}{false, true}}
+}
+
+// Since перебирает пустой since и переданные значения
+func Since(values ...string) Axis {
+	axis := Axis{Name: "since", Values: []interface{}{""}}
+	for _, v := range values {
+		axis.Values = append(axis.Values, v)
+	}
+	return axis
+}
+
+func Limit() Axis {
+	return Axis{Name: "limit", Values: []interface{}{0, 20}}
+}
+
+// Strings - ось из строковых значений
+func Strings(name string, values ...string) Axis {
+	axis := Axis{Name: name}
+	for _, v := range values {
+		axis.Values = append(axis.Values, v)
+	}
+	return axis
+}
+
+// Matrix собирает запрос на каждом сочетании осей, последняя ось меняется быстрее всего.
+// Имя случая - "ось=значение" через пробел.
+func Matrix(axes []Axis, build func(p Params) (string, []interface{})) []Case {
+	cases := []Case{}
+	params := make(Params, len(axes))
+	names := make([]string, len(axes))
+
+	var walk func(i int)
+	walk = func(i int) {
+		if i == len(axes) {
+			text, args := build(params)
+			cases = append(cases, Case{Name: strings.Join(names, " "), Query: text, Args: args})
+			return
+		}
+
+		for _, v := range axes[i].Values {
+			params[axes[i].Name] = v
+			if s, ok := v.(string); ok {
+				names[i] = fmt.Sprintf("%s=%q", axes[i].Name, s)
+			} else {
+				names[i] = fmt.Sprintf("%s=%v", axes[i].Name, v)
+			}
+			walk(i + 1)
+		}
+	}
+	walk(0)
+
+	return cases
+}
@@ -0,0 +1,132 @@
+package response
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+)
+
+// сколько элементов пишется между принудительными отправками клиенту
+const flushEvery = 256
+
+// FlushWriter буферизует запись в ResponseWriter и по запросу отдаёт накопленное клиенту
+type FlushWriter struct {
+	w   http.ResponseWriter
+	buf *bufio.Writer
+}
+
+func NewFlushWriter(w http.ResponseWriter) *FlushWriter {
+	return &FlushWriter{
+		w:   w,
+		buf: bufio.NewWriter(w),
+	}
+}
+
+func (f *FlushWriter) Write(p []byte) (int, error) {
+	return f.buf.Write(p)
+}
+
+func (f *FlushWriter) Flush() error {
+	if err := f.buf.Flush(); err != nil {
+		return err
+	}
+
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// Stream пишет JSON-массив по одному элементу, не собирая его в памяти.
+// Код ответа отправляется вместе с первым элементом, поэтому пока Started() == false
+// обработчик ещё может ответить ошибкой.
+type Stream struct {
+	out     *FlushWriter
+	code    int
+	items   int
+	started bool
+}
+
+func NewStream(w http.ResponseWriter, code int) *Stream {
+	return &Stream{
+		out:  NewFlushWriter(w),
+		code: code,
+	}
+}
+
+func (s *Stream) Started() bool {
+	return s.started
+}
+
+func (s *Stream) start() {
+	s.started = true
+	s.out.w.WriteHeader(s.code)
+	s.out.Write([]byte{'['})
+}
+
+func (s *Stream) Write(item interface{}) error {
+	body, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	if s.started {
+		err = s.WriteRaw([]byte{','}, body)
+	} else {
+		err = s.WriteRaw(body)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.Item()
+}
+
+// WriteRaw пишет готовые куски JSON внутрь массива, для элементов нестандартной формы.
+// Разделители и вложенность - забота вызывающего.
+func (s *Stream) WriteRaw(parts ...[]byte) error {
+	if !s.started {
+		s.start()
+	}
+
+	for _, part := range parts {
+		if _, err := s.out.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Item отмечает, что записан очередной элемент, и раз в flushEvery элементов отдаёт их клиенту
+func (s *Stream) Item() error {
+	s.items++
+	if s.items%flushEvery == 0 {
+		return s.out.Flush()
+	}
+	return nil
+}
+
+// Close дописывает конец массива. После ошибки чтения его вызывать не нужно:
+// оборванный JSON лучше, чем правдоподобный, но неполный список.
+func (s *Stream) Close() error {
+	if !s.started {
+		s.start()
+	}
+
+	s.out.Write([]byte{']'})
+	return s.out.Flush()
+}
+
+// End завершает поток после чтения: без ошибки закрывает массив,
+// с ошибкой отвечает 500, если ещё ничего не отправлено, иначе обрывает ответ
+func (s *Stream) End(err error) error {
+	if err != nil {
+		if !s.started {
+			s.out.w.WriteHeader(http.StatusInternalServerError)
+			return nil
+		}
+		return s.out.Flush()
+	}
+
+	return s.Close()
+}